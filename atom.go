@@ -0,0 +1,201 @@
+package main
+
+import "strings"
+
+// AtomFeed represents an Atom 1.0 feed parsed from XML
+type AtomFeed struct {
+	// Title is the feed's title
+	Title AtomText `xml:"title"`
+	// Subtitle provides details about the feed
+	Subtitle AtomText `xml:"subtitle"`
+	// Link is the list of links related to the feed
+	Link []AtomLink `xml:"link"`
+	// Author is the list of authors of the feed, the default authors of its entries
+	Author []AtomPerson `xml:"author"`
+	// Entry is the list of feed entries
+	Entry []AtomEntry `xml:"entry"`
+}
+
+// AtomEntry represents an individual entry in an Atom feed
+type AtomEntry struct {
+	// ID is the permanent and universally unique identifier of the entry
+	ID string `xml:"id"`
+	// MediaTitle is the Media RSS title of the entry, declared before Title, whose unqualified tag
+	// would catch it too, because the XML decoder fills the first field matching an element
+	MediaTitle string `xml:"http://search.yahoo.com/mrss/ title"`
+	// Title of the entry
+	Title AtomText `xml:"title"`
+	// Link is the list of links related to the entry
+	Link []AtomLink `xml:"link"`
+	// Updated is the last time the entry was modified
+	Updated string `xml:"updated"`
+	// Published is the first publication date of the entry
+	Published string `xml:"published"`
+	// Summary is a short summary of the entry
+	Summary AtomText `xml:"summary"`
+	// Content is the full content of the entry, qualified with the Atom namespace not to catch media:content
+	Content AtomText `xml:"http://www.w3.org/2005/Atom content"`
+	// Author is the list of authors of the entry
+	Author []AtomPerson `xml:"author"`
+	// Category is the list of categories the entry belongs to
+	Category []AtomCategory `xml:"category"`
+	// MediaGroup holds the Media RSS thumbnails and contents of the entry
+	MediaGroup
+	// MediaGroups are the Media RSS thumbnails and contents of the entry grouped in media:group elements
+	MediaGroups []MediaGroup `xml:"http://search.yahoo.com/mrss/ group"`
+}
+
+// AtomPerson represents an author or a contributor of an Atom feed or entry
+type AtomPerson struct {
+	// Name is the human-readable name of the person
+	Name string `xml:"name"`
+	// Email is the email address of the person
+	Email string `xml:"email"`
+}
+
+// AtomCategory represents a category of an Atom entry
+type AtomCategory struct {
+	// Term is the identifier of the category
+	Term string `xml:"term,attr"`
+	// Label is the human-readable label of the category, the term when omitted
+	Label string `xml:"label,attr"`
+}
+
+// AtomLink represents a link element of an Atom feed or entry
+type AtomLink struct {
+	// Href is the URL of the link
+	Href string `xml:"href,attr"`
+	// Rel is the link relation type, "alternate" when omitted
+	Rel string `xml:"rel,attr"`
+	// Type is the media type of the linked resource
+	Type string `xml:"type,attr"`
+	// Length is the size of the linked resource in bytes
+	Length string `xml:"length,attr"`
+}
+
+// AtomText represents an Atom text construct, which may be plain text, HTML, or XHTML
+type AtomText struct {
+	// Type is either "text", "html" or "xhtml", "text" when omitted
+	Type string `xml:"type,attr"`
+	// Body is the character data of the element
+	Body string `xml:",chardata"`
+	// InnerXML is the raw markup of the element, used for XHTML content
+	InnerXML string `xml:",innerxml"`
+}
+
+// String returns the text of the construct, keeping the markup of XHTML content
+func (t AtomText) String() string {
+	if t.Type == "xhtml" {
+		return t.InnerXML
+	}
+	return t.Body
+}
+
+// toRSSFeed normalizes the Atom feed into the RSSFeed model persisted by the aggregator
+func (f *AtomFeed) toRSSFeed() *RSSFeed {
+	var rssFeed RSSFeed
+	rssFeed.Channel.Title = f.Title.String()
+	rssFeed.Channel.Link = alternateLink(f.Link)
+	rssFeed.Channel.Description = f.Subtitle.String()
+	rssFeed.Channel.Item = make([]RSSItem, 0, len(f.Entry))
+	for _, entry := range f.Entry {
+		pubDate := entry.Published
+		if pubDate == "" {
+			pubDate = entry.Updated
+		}
+		description := entry.Summary.String()
+		if description == "" {
+			description = entry.Content.String()
+		}
+		authors := entry.Author
+		if len(authors) == 0 {
+			authors = f.Author
+		}
+		categories := make([]string, 0, len(entry.Category))
+		for _, category := range entry.Category {
+			if category.Label != "" {
+				categories = append(categories, category.Label)
+			} else {
+				categories = append(categories, category.Term)
+			}
+		}
+		rssFeed.Channel.Item = append(rssFeed.Channel.Item, RSSItem{
+			GUID:        entry.ID,
+			Title:       entry.Title.String(),
+			MediaTitle:  entry.MediaTitle,
+			Link:        alternateLink(entry.Link),
+			Description: description,
+			PubDate:     pubDate,
+			Content:     entry.Content.String(),
+			Creator:     atomAuthors(authors),
+			Categories:  categories,
+			Comments:    relatedLink(entry.Link, "replies"),
+			Enclosures:  atomEnclosures(entry.Link),
+			MediaGroup:  entry.MediaGroup,
+			MediaGroups: entry.MediaGroups,
+		})
+	}
+	return &rssFeed
+}
+
+// atomAuthors joins the names of the authors, or their email addresses when they have no name
+func atomAuthors(authors []AtomPerson) string {
+	names := make([]string, 0, len(authors))
+	for _, author := range authors {
+		if author.Name != "" {
+			names = append(names, author.Name)
+		} else if author.Email != "" {
+			names = append(names, author.Email)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// atomEnclosures returns the media files attached to an entry, linked with the "enclosure" relation type
+func atomEnclosures(links []AtomLink) []RSSEnclosure {
+	var enclosures []RSSEnclosure
+	for _, link := range links {
+		if link.Rel == "enclosure" {
+			enclosures = append(enclosures, RSSEnclosure{URL: link.Href, Type: link.Type, Length: link.Length})
+		}
+	}
+	return enclosures
+}
+
+// relatedLink returns the URL of the first link of the relation type, of type text/html if any
+func relatedLink(links []AtomLink, rel string) string {
+	var related string
+	for _, link := range links {
+		if link.Rel != rel {
+			continue
+		}
+		if link.Type == "" || link.Type == "text/html" {
+			return link.Href
+		}
+		if related == "" {
+			related = link.Href
+		}
+	}
+	return related
+}
+
+// alternateLink picks the URL pointing to the HTML version of the feed or entry,
+// it prefers an "alternate" link of type text/html, then any "alternate" link, then the first link
+func alternateLink(links []AtomLink) string {
+	var alternate string
+	for _, link := range links {
+		if link.Rel != "" && link.Rel != "alternate" {
+			continue
+		}
+		if link.Type == "" || link.Type == "text/html" {
+			return link.Href
+		}
+		if alternate == "" {
+			alternate = link.Href
+		}
+	}
+	if alternate == "" && len(links) > 0 {
+		return links[0].Href
+	}
+	return alternate
+}
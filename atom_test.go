@@ -0,0 +1,199 @@
+package main
+
+import "testing"
+
+func TestParseFeedAtomEntries(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry string
+		want  RSSItem
+	}{
+		{
+			name: "full entry",
+			entry: `<entry>
+				<id>urn:uuid:1</id>
+				<title>Atom-Powered Robots Run Amok</title>
+				<link href="https://example.com/2003/12/13/atom03"/>
+				<link rel="replies" type="application/atom+xml" href="https://example.com/comments.xml"/>
+				<link rel="replies" type="text/html" href="https://example.com/2003/12/13/atom03#comments"/>
+				<link rel="enclosure" type="audio/mpeg" length="1337" href="https://example.com/audio.mp3"/>
+				<updated>2003-12-13T18:30:02Z</updated>
+				<published>2003-12-13T08:29:29-04:00</published>
+				<summary>Some text.</summary>
+				<content type="html">&lt;p&gt;Full text.&lt;/p&gt;</content>
+				<author><name>John Doe</name></author>
+				<author><email>jane@example.com</email></author>
+				<category term="robots" label="Robots"/>
+				<category term="atom"/>
+			</entry>`,
+			want: RSSItem{
+				GUID:        "urn:uuid:1",
+				Title:       "Atom-Powered Robots Run Amok",
+				Link:        "https://example.com/2003/12/13/atom03",
+				Description: "Some text.",
+				PubDate:     "2003-12-13T08:29:29-04:00",
+				Content:     "<p>Full text.</p>",
+				Creator:     "John Doe, jane@example.com",
+				Categories:  []string{"Robots", "atom"},
+				Comments:    "https://example.com/2003/12/13/atom03#comments",
+			},
+		},
+		{
+			name: "alternate link preferred",
+			entry: `<entry>
+				<id>urn:uuid:1</id>
+				<link rel="self" href="https://example.com/entry.xml"/>
+				<link rel="alternate" type="application/pdf" href="https://example.com/entry.pdf"/>
+				<link rel="alternate" type="text/html" href="https://example.com/entry.html"/>
+			</entry>`,
+			want: RSSItem{GUID: "urn:uuid:1", Link: "https://example.com/entry.html"},
+		},
+		{
+			name: "alternate link of any type",
+			entry: `<entry>
+				<id>urn:uuid:1</id>
+				<link rel="self" href="https://example.com/entry.xml"/>
+				<link rel="alternate" type="application/pdf" href="https://example.com/entry.pdf"/>
+			</entry>`,
+			want: RSSItem{GUID: "urn:uuid:1", Link: "https://example.com/entry.pdf"},
+		},
+		{
+			name: "first link without alternate",
+			entry: `<entry>
+				<id>urn:uuid:1</id>
+				<link rel="related" href="https://example.com/related"/>
+				<link rel="self" href="https://example.com/entry.xml"/>
+			</entry>`,
+			want: RSSItem{GUID: "urn:uuid:1", Link: "https://example.com/related"},
+		},
+		{
+			name: "content without summary",
+			entry: `<entry>
+				<id>urn:uuid:1</id>
+				<content type="html">&lt;p&gt;Full text.&lt;/p&gt;</content>
+			</entry>`,
+			want: RSSItem{GUID: "urn:uuid:1", Description: "<p>Full text.</p>", Content: "<p>Full text.</p>"},
+		},
+		{
+			name: "XHTML content",
+			entry: `<entry>
+				<id>urn:uuid:1</id>
+				<content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Full <b>text</b>.</p></div></content>
+			</entry>`,
+			want: RSSItem{
+				GUID:        "urn:uuid:1",
+				Description: `<div xmlns="http://www.w3.org/1999/xhtml"><p>Full <b>text</b>.</p></div>`,
+				Content:     `<div xmlns="http://www.w3.org/1999/xhtml"><p>Full <b>text</b>.</p></div>`,
+			},
+		},
+		{
+			name: "media content not taken for content",
+			entry: `<entry>
+				<id>urn:uuid:1</id>
+				<summary>Summary</summary>
+				<media:content url="https://example.com/video.mp4" medium="video"/>
+			</entry>`,
+			want: RSSItem{GUID: "urn:uuid:1", Description: "Summary"},
+		},
+		{
+			name: "updated without published",
+			entry: `<entry>
+				<id>urn:uuid:1</id>
+				<updated>2003-12-13T18:30:02Z</updated>
+			</entry>`,
+			want: RSSItem{GUID: "urn:uuid:1", PubDate: "2003-12-13T18:30:02Z"},
+		},
+		{
+			name: "media title",
+			entry: `<entry>
+				<id>urn:uuid:1</id>
+				<title>Entry title</title>
+				<media:title>MT</media:title>
+				<media:description>MD</media:description>
+				<summary>Summary</summary>
+			</entry>`,
+			want: RSSItem{GUID: "urn:uuid:1", Title: "Entry title", Description: "Summary"},
+		},
+		{
+			name: "media title only",
+			entry: `<entry>
+				<id>urn:uuid:1</id>
+				<media:title>MT</media:title>
+			</entry>`,
+			want: RSSItem{GUID: "urn:uuid:1", Title: "MT"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed := mustParseFeed(t, atomDocument(tt.entry), "application/atom+xml")
+			if len(feed.Channel.Item) != 1 {
+				t.Fatalf("got %d items, want 1", len(feed.Channel.Item))
+			}
+			checkItem(t, feed.Channel.Item[0], tt.want)
+		})
+	}
+}
+
+func TestParseFeedAtomEnclosures(t *testing.T) {
+	feed := mustParseFeed(t, atomDocument(`<entry>
+		<id>urn:uuid:1</id>
+		<link rel="enclosure" type="audio/mpeg" length="1337" href="https://example.com/1.mp3"/>
+		<link rel="enclosure" href="https://example.com/2.mp3"/>
+		<link href="https://example.com/entry"/>
+	</entry>`), "application/atom+xml")
+	want := []RSSEnclosure{
+		{URL: "https://example.com/1.mp3", Type: "audio/mpeg", Length: "1337"},
+		{URL: "https://example.com/2.mp3"},
+	}
+	got := feed.Channel.Item[0].Enclosures
+	if len(got) != len(want) {
+		t.Fatalf("got %d enclosures, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("enclosure %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseFeedAtomAuthors(t *testing.T) {
+	feed := mustParseFeed(t, `<feed xmlns="http://www.w3.org/2005/Atom">
+		<author><name>Feed Author</name></author>
+		<entry><id>urn:uuid:1</id><author><name>Entry Author</name></author></entry>
+		<entry><id>urn:uuid:2</id></entry>
+	</feed>`, "application/atom+xml")
+	for i, want := range []string{"Entry Author", "Feed Author"} {
+		if got := feed.Channel.Item[i].Creator; got != want {
+			t.Errorf("creator of entry %d = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestParseFeedAtomChannel(t *testing.T) {
+	feed := mustParseFeed(t, `<feed xmlns="http://www.w3.org/2005/Atom">
+		<title type="html">Example &amp;amp; Co</title>
+		<subtitle>All the news</subtitle>
+		<link rel="self" href="https://example.com/feed.atom"/>
+		<link rel="alternate" type="text/html" href="https://example.com/"/>
+	</feed>`, "application/atom+xml")
+	if got, want := feed.Channel.Title, "Example & Co"; got != want {
+		t.Errorf("title = %q, want %q", got, want)
+	}
+	if got, want := feed.Channel.Link, "https://example.com/"; got != want {
+		t.Errorf("link = %q, want %q", got, want)
+	}
+	if got, want := feed.Channel.Description, "All the news"; got != want {
+		t.Errorf("description = %q, want %q", got, want)
+	}
+}
+
+// atomDocument wraps the entries in an Atom feed declaring the namespaces entries use
+func atomDocument(entries string) string {
+	return `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:media="http://search.yahoo.com/mrss/">
+	<title>Example Feed</title>
+	<link href="https://example.com/"/>
+	<id>urn:uuid:feed</id>
+	` + entries + `
+</feed>`
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// htmlCharsetPrescanSize is the number of bytes at the start of an HTML document in which its charset
+// is looked for, as web browsers do
+const htmlCharsetPrescanSize = 1024
+
+// charsetReader returns a reader decoding the input from the named charset into UTF-8,
+// with the signature expected by xml.Decoder.CharsetReader. The labels are those of the WHATWG
+// Encoding Standard, which decodes ISO-8859-1 and US-ASCII as windows-1252, as web browsers do,
+// because such documents often contain its curly quotes and dashes.
+func charsetReader(label string, input io.Reader) (io.Reader, error) {
+	enc, err := htmlindex.Get(strings.TrimSpace(label))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset: %q", label)
+	}
+	if name, _ := htmlindex.Name(enc); name == "utf-8" {
+		return input, nil
+	}
+	return enc.NewDecoder().Reader(input), nil
+}
+
+// utf8CharsetReader is an xml.Decoder.CharsetReader ignoring the encoding of the XML declaration,
+// for the documents whose charset was already decoded from the HTTP Content-Type
+func utf8CharsetReader(_ string, input io.Reader) (io.Reader, error) {
+	return input, nil
+}
+
+// bomCharset returns the charset of a document declared by its byte order mark, empty when it has none
+func bomCharset(doc []byte) string {
+	switch {
+	case bytes.HasPrefix(doc, []byte("\xef\xbb\xbf")):
+		return "utf-8"
+	case bytes.HasPrefix(doc, []byte("\xfe\xff")):
+		return "utf-16be"
+	case bytes.HasPrefix(doc, []byte("\xff\xfe")):
+		return "utf-16le"
+	}
+	return ""
+}
+
+// metaCharset returns the charset of an HTML document declared by a meta element at its start,
+// empty when it has none
+func metaCharset(doc []byte) string {
+	head := doc[:min(len(doc), htmlCharsetPrescanSize)]
+	for _, tok := range tokenizeHTML(string(head)) {
+		if tok.Type != htmlStartTag && tok.Type != htmlSelfClosingTag || tok.Name != "meta" {
+			continue
+		}
+		charset := strings.TrimSpace(tok.attr("charset"))
+		if charset == "" && strings.EqualFold(strings.TrimSpace(tok.attr("http-equiv")), "content-type") {
+			charset = contentCharset(tok.attr("content"))
+		}
+		// a document whose meta elements could be read as ASCII isn't in UTF-16, whatever they declare
+		if strings.HasPrefix(strings.ToLower(charset), "utf-16") {
+			return "utf-8"
+		}
+		if charset != "" {
+			return charset
+		}
+	}
+	return ""
+}
+
+// contentCharset returns the charset of the content attribute of a meta element declaring the content type,
+// such as "text/html; charset=iso-8859-1", empty when it has none
+func contentCharset(content string) string {
+	i := indexFold(content, "charset")
+	if i < 0 {
+		return ""
+	}
+	rest := strings.TrimLeft(content[i+len("charset"):], " \t\n\f\r")
+	if !strings.HasPrefix(rest, "=") {
+		return ""
+	}
+	rest = strings.TrimLeft(rest[1:], " \t\n\f\r")
+	if rest != "" && (rest[0] == '"' || rest[0] == '\'') {
+		end := strings.IndexByte(rest[1:], rest[0])
+		if end < 0 {
+			return ""
+		}
+		return rest[1 : end+1]
+	}
+	if end := strings.IndexAny(rest, " \t\n\f\r;"); end >= 0 {
+		rest = rest[:end]
+	}
+	return rest
+}
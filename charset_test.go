@@ -0,0 +1,108 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCharsetReader(t *testing.T) {
+	tests := []struct {
+		label string
+		input string
+		want  string
+	}{
+		{"utf-8", "caf\xc3\xa9", "café"},
+		{" UTF8 ", "caf\xc3\xa9", "café"},
+		{"windows-1252", "\x93caf\xe9\x94 \x80", "“café” €"},
+		{"ISO-8859-1", "\x93caf\xe9\x94", "“café”"},
+		{"us-ascii", "\x97", "—"},
+		{"latin1", "caf\xe9", "café"},
+		{"iso-8859-15", "\xa4 \xbd", "€ œ"},
+		{"iso-8859-2", "\xa3\xf3d\xbc", "Łódź"},
+		{"windows-1250", "\x8c\xe8", "Śč"},
+		{"windows-1251", "\xcf\xf0\xe8\xe2\xe5\xf2", "Привет"},
+		{"koi8-r", "\xf0\xd2\xc9\xd7\xc5\xd4", "Привет"},
+		{"shift_jis", "\x93\xfa\x96\x7b", "日本"},
+		{"euc-kr", "\xc7\xd1\xb1\xb9", "한국"},
+		{"gbk", "\xd6\xd0\xce\xc4", "中文"},
+		{"big5", "\xa4\xa4\xa4\xe5", "中文"},
+		{"utf-16le", "h\x00i\x00", "hi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			r, err := charsetReader(tt.label, strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("charsetReader() error = %v", err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("io.ReadAll() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("decoded %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCharsetReaderUnsupported(t *testing.T) {
+	for _, label := range []string{"", "klingon", "utf-9"} {
+		if _, err := charsetReader(label, strings.NewReader("")); err == nil {
+			t.Errorf("charsetReader(%q) error = nil, want an error", label)
+		}
+	}
+}
+
+func TestMetaCharset(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{"charset attribute", `<html><head><meta charset="windows-1251"><title>x</title>`, "windows-1251"},
+		{"unquoted charset attribute", `<meta charset=iso-8859-2>`, "iso-8859-2"},
+		{"http-equiv", `<meta http-equiv="Content-Type" content="text/html; charset=ISO-8859-1">`, "ISO-8859-1"},
+		{"http-equiv quoted charset", `<meta http-equiv="content-type" content="text/html;charset='koi8-r'">`, "koi8-r"},
+		{"content without http-equiv", `<meta name="x" content="text/html; charset=koi8-r">`, ""},
+		{"self-closing", `<meta charset="shift_jis"/>`, "shift_jis"},
+		{"first declaration", `<meta charset="koi8-r"><meta charset="windows-1251">`, "koi8-r"},
+		{"UTF-16 read as UTF-8", `<meta charset="utf-16">`, "utf-8"},
+		{"beyond the prescan", "<head>" + strings.Repeat(" ", htmlCharsetPrescanSize) + `<meta charset="koi8-r">`, ""},
+		{"no declaration", `<html><head><title>x</title></head></html>`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := metaCharset([]byte(tt.doc)); got != tt.want {
+				t.Errorf("metaCharset() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeHTML(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		charset string
+		want    string
+	}{
+		{"HTTP charset", "<p>caf\xe9</p>", "windows-1252", "<p>café</p>"},
+		{"meta charset", `<meta charset="windows-1251"><p>` + "\xcf\xf0\xe8\xe2\xe5\xf2", "",
+			`<meta charset="windows-1251"><p>Привет`},
+		{"HTTP charset over meta charset", `<meta charset="windows-1251"><p>` + "caf\xe9", "iso-8859-1",
+			`<meta charset="windows-1251"><p>café`},
+		{"byte order mark over HTTP charset", "\xef\xbb\xbf<p>caf\xc3\xa9", "windows-1252", "<p>café"},
+		{"UTF-16 byte order mark", "\xff\xfe<\x00p\x00>\x00", "", "<p>"},
+		{"undeclared invalid UTF-8", "<p>caf\xe9</p>", "", "<p>caf�</p>"},
+		{"unsupported charset", "<p>caf\xe9</p>", "klingon", "<p>caf�</p>"},
+		{"UTF-8", "<p>café</p>", "", "<p>café</p>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(decodeHTML([]byte(tt.doc), tt.charset)); got != tt.want {
+				t.Errorf("decodeHTML() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
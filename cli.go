@@ -24,21 +24,25 @@ func initCli() {
 	if err != nil {
 		log.Fatalf("Error")
 	}
-	s := &state{dbCfg: &dbCfg, dbQr: dbQr}
+	s := &state{dbCfg: &dbCfg, dbCon: dbCon, dbQr: dbQr}
 	cmds := commands{}
 	handlers := map[string]commandHandler{
-		"login":      handlerLogin,
-		"register":   handlerRegister,
-		"reset":      handlerReset,
-		"list-users": handlerListUsers,
-		"agg":        handlerAgg,
-		"addfeed":    middlewareLoggedIn(handlerAddFeed),
-		"feeds":      handlerListFeeds,
-		"follow":     middlewareLoggedIn(handlerFollow),
-		"following":  middlewareLoggedIn(handlerListFeedFollows),
-		"unfollow":   middlewareLoggedIn(handlerUnfollow),
-		"browse":     middlewareLoggedIn(handlerBrowse),
-		"help":       handlerHelp,
+		"login":       handlerLogin,
+		"register":    handlerRegister,
+		"reset":       handlerReset,
+		"list-users":  handlerListUsers,
+		"agg":         handlerAgg,
+		"addfeed":     middlewareLoggedIn(handlerAddFeed),
+		"feeds":       handlerListFeeds,
+		"revive":      handlerRevive,
+		"setinterval": handlerSetInterval,
+		"fullcontent": handlerFullContent,
+		"follow":      middlewareLoggedIn(handlerFollow),
+		"following":   middlewareLoggedIn(handlerListFeedFollows),
+		"unfollow":    middlewareLoggedIn(handlerUnfollow),
+		"browse":      middlewareLoggedIn(handlerBrowse),
+		"download":    handlerDownload,
+		"help":        handlerHelp,
 	}
 	for cmd, handler := range handlers {
 		cmds.register(cmd, handler)
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"database/sql"
+	"flag"
+
 	"github.com/alnah/go-feedo/internal/config"
 	"github.com/alnah/go-feedo/internal/database"
 )
 
 // state gives to the handlers an access to the application state and the database queries
 type state struct {
+	dbCon *sql.DB
 	dbQr  *database.Queries
 	dbCfg *config.DatabaseConfig
 }
@@ -45,3 +49,19 @@ func (c *commands) run(s *state, cmd command) error {
 	}
 	return nil
 }
+
+// parseFlags parses the flags of a command wherever they appear among its arguments,
+// and returns the remaining positional arguments in order
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
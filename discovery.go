@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/alnah/go-feedo/internal/database"
+)
+
+// feedLinkTypes are the media types of the feeds advertised by web pages with <link rel="alternate"> tags
+var feedLinkTypes = []string{
+	"application/rss+xml", "application/atom+xml", "application/feed+json", "application/rdf+xml",
+}
+
+// feedProbePaths are the paths where websites commonly serve their feed, tried when their page links to none
+var feedProbePaths = []string{"/feed", "/rss", "/rss.xml", "/feed.xml", "/atom.xml", "/index.xml", "/feed.json"}
+
+// feedCandidate is a feed found by discoverFeeds
+type feedCandidate struct {
+	// URL is the URL of the feed
+	URL string
+	// Title is the title of the feed, as given by the link to it or by the feed itself
+	Title string
+}
+
+// errPageUnavailable is returned by discoverFeeds when the page can't be fetched at all
+var errPageUnavailable = errors.New("page unavailable")
+
+// discoverFeeds finds the feeds of a website. The URL is returned as is when it's a feed itself,
+// otherwise the feeds the web page links to with <link rel="alternate"> tags are returned, or,
+// when it links to none, the first feed found at the paths where websites commonly serve them.
+// The error wraps errPageUnavailable when the page couldn't be fetched.
+func discoverFeeds(ctx context.Context, pageURL string) ([]feedCandidate, error) {
+	page, err := fetchPage(ctx, pageURL, feedAcceptHeader+", text/html;q=0.5", defaultAggOptions.maxBodySize)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errPageUnavailable, err)
+	}
+	if !page.isHTML() {
+		rssFeed, err := parseFeed(bytes.NewReader(page.Body), page.MediaType)
+		if err != nil {
+			return nil, err
+		}
+		return []feedCandidate{{URL: pageURL, Title: normalizeText(rssFeed.Channel.Title)}}, nil
+	}
+	if candidates := feedLinks(string(page.Body), page.URL); len(candidates) > 0 {
+		return candidates, nil
+	}
+	// the same feed is often served at several of the paths, so the probing stops at the first one found
+	for _, probePath := range feedProbePaths {
+		probeURL := page.URL.ResolveReference(&url.URL{Path: probePath}).String()
+		feedRes, err := fetchFeed(ctx, database.Feed{Url: probeURL}, defaultAggOptions.maxBodySize)
+		if err != nil {
+			continue
+		}
+		if feedRes.PermanentURL != "" {
+			probeURL = feedRes.PermanentURL
+		}
+		return []feedCandidate{{URL: probeURL, Title: feedRes.Feed.Channel.Title}}, nil
+	}
+	return nil, nil
+}
+
+// feedLinks returns the feeds an HTML page links to with <link rel="alternate"> tags,
+// their URLs resolved against the base URL of the page
+func feedLinks(doc string, pageURL *url.URL) []feedCandidate {
+	base := pageURL
+	var candidates []feedCandidate
+	for _, tok := range tokenizeHTML(doc) {
+		if tok.Type != htmlStartTag && tok.Type != htmlSelfClosingTag {
+			continue
+		}
+		if tok.Name == "body" {
+			break
+		}
+		if tok.Name == "base" {
+			if href, err := url.Parse(strings.TrimSpace(tok.attr("href"))); err == nil && tok.attr("href") != "" {
+				base = pageURL.ResolveReference(href)
+			}
+			continue
+		}
+		if tok.Name != "link" || tok.attr("href") == "" ||
+			!slices.Contains(strings.Fields(strings.ToLower(tok.attr("rel"))), "alternate") {
+			continue
+		}
+		mediaType := strings.ToLower(strings.TrimSpace(strings.Split(tok.attr("type"), ";")[0]))
+		if !slices.Contains(feedLinkTypes, mediaType) {
+			continue
+		}
+		feedURL := resolveURL(base, tok.attr("href"))
+		if !slices.ContainsFunc(candidates, func(c feedCandidate) bool { return c.URL == feedURL }) {
+			candidates = append(candidates, feedCandidate{URL: feedURL, Title: normalizeText(tok.attr("title"))})
+		}
+	}
+	return candidates
+}
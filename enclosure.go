@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alnah/go-feedo/internal/database"
+	"github.com/google/uuid"
+)
+
+// partialDownloadSuffix is appended to the name of the files being downloaded, until they're complete
+const partialDownloadSuffix = ".part"
+
+// enclosureLeaseDuration is how long an enclosure being downloaded is reserved for the aggregator instance
+// or the download command that claimed it
+const enclosureLeaseDuration = time.Hour
+
+// enclosureDownloadTimeout bounds the download of a media file, shorter than the lease of its enclosure
+// so that no other instance claims it while it's downloaded. An unfinished download resumes the next time.
+const enclosureDownloadTimeout = 50 * time.Minute
+
+// maxDownloadAttempts is the number of times the aggregator tries to download a queued enclosure
+const maxDownloadAttempts = 5
+
+// enclosureRetryDelay is how long the aggregator waits before trying again a failed download,
+// the delay doubling with each attempt
+const enclosureRetryDelay = 15 * time.Minute
+
+// storeEnclosures creates or updates the enclosures of the item on its post,
+// and queues them for download when the aggregator was asked to download them
+func storeEnclosures(ctx context.Context, s *state, post database.Post, item RSSItem, opts aggOptions) {
+	var downloadRequestedAt sql.NullTime
+	if opts.downloadEnclosures {
+		downloadRequestedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	}
+	for _, rssEnclosure := range item.Enclosures {
+		length, _ := strconv.ParseInt(rssEnclosure.Length, 10, 64)
+		_, err := s.dbQr.CreateEnclosure(ctx, database.CreateEnclosureParams{
+			ID:                  uuid.New(),
+			CreatedAt:           time.Now().UTC(),
+			UpdatedAt:           time.Now().UTC(),
+			PostID:              post.ID,
+			Url:                 rssEnclosure.URL,
+			MimeType:            rssEnclosure.Type,
+			Length:              max(length, 0),
+			DurationSeconds:     int32(parseITunesDuration(item.Duration) / time.Second),
+			Episode:             parseEpisodeNumber(item.Episode),
+			Season:              parseEpisodeNumber(item.Season),
+			DownloadRequestedAt: downloadRequestedAt,
+		})
+		if err != nil {
+			log.Printf("couldn't create enclosure of post %q: %v", post.Title, err)
+		}
+	}
+}
+
+// downloadQueuedEnclosures downloads the enclosures queued by the collections, one at a time,
+// until the queue is empty or ctx is done. Each enclosure is claimed with a lease, which keeps
+// the other aggregator instances from downloading it into the same file, and the in-flight download
+// goes on with workCtx. The failed downloads are tried again after a backoff, up to maxDownloadAttempts times.
+func downloadQueuedEnclosures(ctx, workCtx context.Context, s *state) {
+	for ctx.Err() == nil {
+		now := time.Now().UTC()
+		enclosure, err := s.dbQr.ClaimEnclosureToDownload(ctx, database.ClaimEnclosureToDownloadParams{
+			UpdatedAt:        now,
+			LeaseExpiresAt:   sql.NullTime{Time: now.Add(enclosureLeaseDuration), Valid: true},
+			DownloadAttempts: maxDownloadAttempts,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("couldn't claim enclosure to download: %v", err)
+			}
+			return
+		}
+		retryDelay := backoffDelay(enclosureRetryDelay, enclosure.DownloadAttempts)
+		filePath, err := downloadEnclosure(workCtx, s, enclosure, retryDelay)
+		if err != nil {
+			log.Printf("couldn't download enclosure %s (attempt %d of %d): %v",
+				enclosure.Url, enclosure.DownloadAttempts, maxDownloadAttempts, err)
+			continue
+		}
+		log.Printf("Enclosure %s downloaded to %s", enclosure.Url, filePath)
+	}
+}
+
+// parseITunesDuration parses an iTunes duration, given in seconds or in [HH:]MM:SS format,
+// zero when it's missing or invalid
+func parseITunesDuration(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	var seconds float64
+	for _, part := range strings.Split(value, ":") {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 {
+			return 0
+		}
+		seconds = seconds*60 + n
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// parseEpisodeNumber parses an iTunes episode or season number, zero when it's missing or invalid
+func parseEpisodeNumber(value string) int32 {
+	n, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return int32(n)
+}
+
+// downloadEnclosure downloads the media file of the enclosure into the download directory, resuming
+// a partial download left by a previous attempt, and records the path and SHA-256 checksum of the file.
+// An enclosure already downloaded isn't downloaded again while its file matches the recorded checksum.
+// The enclosure must be claimed by the caller, its lease being released when the download isn't recorded.
+// After a failure, the lease is kept for retryDelay, so that the download isn't tried again right away.
+func downloadEnclosure(ctx context.Context, s *state, enclosure database.Enclosure, retryDelay time.Duration,
+) (filePath string, err error) {
+	recorded := false
+	defer func() {
+		if recorded {
+			return
+		}
+		// the lease is released even when the download was interrupted by a shutdown,
+		// and the interrupted download resumes without waiting the next time
+		now := time.Now().UTC()
+		var leaseExpiresAt sql.NullTime
+		if err != nil && ctx.Err() == nil && retryDelay > 0 {
+			leaseExpiresAt = sql.NullTime{Time: now.Add(retryDelay), Valid: true}
+		}
+		releaseErr := s.dbQr.ReleaseEnclosureLease(context.WithoutCancel(ctx), database.ReleaseEnclosureLeaseParams{
+			ID:             enclosure.ID,
+			UpdatedAt:      now,
+			LeaseExpiresAt: leaseExpiresAt,
+		})
+		if releaseErr != nil {
+			err = errors.Join(err, fmt.Errorf("couldn't release lease of enclosure: %w", releaseErr))
+		}
+	}()
+	if enclosure.DownloadedAt.Valid {
+		sum, err := fileChecksum(enclosure.FilePath)
+		if err == nil && sum == enclosure.Sha256 {
+			return enclosure.FilePath, nil
+		}
+		log.Printf("File %s is missing or corrupted, downloading it again", enclosure.FilePath)
+	}
+	dir, err := s.dbCfg.DownloadDirectory()
+	if err != nil {
+		return "", fmt.Errorf("couldn't get download directory: %w", err)
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("couldn't create download directory: %w", err)
+	}
+	filePath = filepath.Join(dir, enclosureFileName(enclosure))
+	partPath := filePath + partialDownloadSuffix
+	sum, err := fetchEnclosure(ctx, enclosure.Url, partPath)
+	if err != nil {
+		return "", err
+	}
+	if err = os.Rename(partPath, filePath); err != nil {
+		return "", fmt.Errorf("couldn't move downloaded file: %w", err)
+	}
+	if err = s.dbQr.MarkEnclosureDownloaded(ctx, database.MarkEnclosureDownloadedParams{
+		ID:        enclosure.ID,
+		UpdatedAt: time.Now().UTC(),
+		FilePath:  filePath,
+		Sha256:    sum,
+	}); err != nil {
+		return "", fmt.Errorf("couldn't mark enclosure as downloaded: %w", err)
+	}
+	recorded = true
+	return filePath, nil
+}
+
+// fetchEnclosure downloads a media file into the partial file, resuming from its end with a range request,
+// and returns the hex-encoded SHA-256 checksum of the complete file.
+// The size of the file is checked against the one announced by the server, and an incomplete file is kept
+// so that the next attempt resumes it.
+func fetchEnclosure(ctx context.Context, enclosureURL, partPath string) (string, error) {
+	file, err := os.OpenFile(partPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return "", fmt.Errorf("couldn't open partial download: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+	// hashing what was already downloaded moves to the end of the file, where the download resumes
+	hash := sha256.New()
+	offset, err := io.Copy(hash, file)
+	if err != nil {
+		return "", fmt.Errorf("couldn't read partial download: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", enclosureURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("Error creating a new request: %q", err)
+	}
+	req.Header.Set("User-Agent", "go-feedo")
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	client := &http.Client{Timeout: enclosureDownloadTimeout}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Error getting response: %q", err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	total := int64(-1)
+	switch {
+	case res.StatusCode == http.StatusPartialContent:
+		var start int64
+		if _, err = fmt.Sscanf(res.Header.Get("Content-Range"), "bytes %d-", &start); err != nil || start != offset {
+			return "", fmt.Errorf("unexpected content range: %q", res.Header.Get("Content-Range"))
+		}
+		if res.ContentLength >= 0 {
+			total = offset + res.ContentLength
+		}
+	case res.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// the previous attempt downloaded the whole file, but couldn't move it
+		return hex.EncodeToString(hash.Sum(nil)), nil
+	case res.StatusCode >= 200 && res.StatusCode <= 299:
+		// the server ignored the range, the download starts over
+		if err = file.Truncate(0); err != nil {
+			return "", fmt.Errorf("couldn't restart download: %w", err)
+		}
+		if _, err = file.Seek(0, io.SeekStart); err != nil {
+			return "", fmt.Errorf("couldn't restart download: %w", err)
+		}
+		hash.Reset()
+		offset = 0
+		total = res.ContentLength
+	default:
+		return "", &statusError{StatusCode: res.StatusCode}
+	}
+	n, err := io.Copy(io.MultiWriter(file, hash), res.Body)
+	if err != nil {
+		return "", fmt.Errorf("download interrupted after %d bytes, it will resume: %w", offset+n, err)
+	}
+	if total >= 0 && offset+n != total {
+		return "", fmt.Errorf("incomplete download: %d bytes out of %d, it will resume", offset+n, total)
+	}
+	if err = file.Sync(); err != nil {
+		return "", fmt.Errorf("couldn't write downloaded file: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// enclosureFileName returns the name of the downloaded file of the enclosure: the name of the file in its URL,
+// prefixed with the start of the enclosure ID to avoid collisions, with an extension matching its MIME type
+// when it has none
+func enclosureFileName(enclosure database.Enclosure) string {
+	name := "enclosure"
+	if u, err := url.Parse(enclosure.Url); err == nil {
+		if base := path.Base(u.Path); base != "." && base != "/" {
+			name = strings.Map(func(r rune) rune {
+				if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("._-", r) {
+					return r
+				}
+				return '_'
+			}, base)
+		}
+	}
+	if path.Ext(name) == "" {
+		if exts, err := mime.ExtensionsByType(enclosure.MimeType); err == nil && len(exts) > 0 {
+			name += exts[0]
+		}
+	}
+	return enclosure.ID.String()[:8] + "-" + name
+}
+
+// fileChecksum returns the hex-encoded SHA-256 checksum of the file
+func fileChecksum(filePath string) (string, error) {
+	if filePath == "" {
+		return "", errors.New("no file")
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = file.Close() }()
+	hash := sha256.New()
+	if _, err = io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
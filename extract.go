@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// articleMinParagraph is the minimum length of the text of a paragraph for it to count in the score of its container
+const articleMinParagraph = 25
+
+// articleClassWeight is the score given to a container whose class or id hints it holds, or doesn't hold, the article
+const articleClassWeight = 25
+
+// articlePositiveHints are the words of the classes and ids of the containers likely to hold the article
+var articlePositiveHints = []string{"article", "body", "content", "entry", "main", "page", "post", "story", "text"}
+
+// articleNegativeHints are the words of the classes and ids of the containers unlikely to hold the article
+var articleNegativeHints = []string{
+	"ad-", "comment", "footer", "footnote", "menu", "meta", "nav", "promo", "related", "share", "sidebar",
+	"social", "sponsor", "widget",
+}
+
+// articleSkippedElements are the elements never part of the article, dropped with their content
+var articleSkippedElements = []string{
+	"script", "style", "noscript", "template", "iframe", "object", "embed", "svg", "form", "head",
+	"nav", "header", "footer", "aside", "button", "select", "textarea",
+}
+
+// articleImplicitlyClosed are the elements left open by malformed pages,
+// closed when a block starting another one of them opens
+var articleImplicitlyClosed = []string{"p", "li", "dt", "dd"}
+
+// htmlNode is a node of the document tree built from HTML tokens, an element or a text node
+type htmlNode struct {
+	// tok is the start tag of an element, or the text of a text node
+	tok      htmlToken
+	parent   *htmlNode
+	children []*htmlNode
+	// score is the readability score of the element as a container of the article
+	score float64
+}
+
+// webPage is a document downloaded by fetchPage
+type webPage struct {
+	// Body is the content of the document, decoded into UTF-8 when it declared a legacy charset
+	Body []byte
+	// MediaType is the media type of the document, without its parameters
+	MediaType string
+	// URL is the URL of the document, after redirections
+	URL *url.URL
+}
+
+// isHTML reports whether the page is an HTML document
+func (p *webPage) isHTML() bool {
+	return p.MediaType == "text/html" || p.MediaType == "application/xhtml+xml"
+}
+
+// fetchPage downloads a document accepting the given media types, up to maxBodySize bytes
+func fetchPage(ctx context.Context, pageURL, accept string, maxBodySize int64) (*webPage, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating a new request: %q", err)
+	}
+	req.Header.Set("User-Agent", "go-feedo")
+	req.Header.Set("Accept", accept)
+	client := &http.Client{Timeout: 10 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting response: %q", err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, &statusError{StatusCode: res.StatusCode}
+	}
+	var body io.Reader = http.MaxBytesReader(nil, res.Body, maxBodySize)
+	page := &webPage{URL: res.Request.URL}
+	mediaType, params, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if err == nil {
+		page.MediaType = mediaType
+	}
+	if page.Body, err = io.ReadAll(body); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, fmt.Errorf("response body too large: the limit is %d bytes", maxBodySize)
+		}
+		return nil, fmt.Errorf("Error reading response body: %q", err)
+	}
+	// the charset of feeds is decoded by their XML decoder
+	if page.isHTML() {
+		page.Body = decodeHTML(page.Body, params["charset"])
+	}
+	return page, nil
+}
+
+// decodeHTML decodes an HTML document into UTF-8 from the charset declared by its byte order mark,
+// by its HTTP Content-Type, or else by a meta element, as web browsers do. Documents in unknown
+// or unsupported charsets are read as UTF-8, with their invalid bytes replaced, so that their text
+// can always be stored.
+func decodeHTML(doc []byte, charset string) []byte {
+	if bom := bomCharset(doc); bom != "" {
+		charset = bom
+	} else if charset == "" {
+		charset = metaCharset(doc)
+	}
+	if charset != "" {
+		if decoder, err := charsetReader(charset, bytes.NewReader(doc)); err == nil {
+			if decoded, err := io.ReadAll(decoder); err == nil {
+				doc = decoded
+			}
+		}
+	}
+	doc = bytes.TrimPrefix(doc, []byte("\xef\xbb\xbf"))
+	return bytes.ToValidUTF8(doc, []byte("\uFFFD"))
+}
+
+// fetchArticle downloads the web page of a post, and extracts the sanitized HTML of its main article
+func fetchArticle(ctx context.Context, pageURL string, maxBodySize int64) (string, error) {
+	page, err := fetchPage(ctx, pageURL, "text/html, application/xhtml+xml;q=0.9", maxBodySize)
+	if err != nil {
+		return "", err
+	}
+	if page.MediaType != "" && !page.isHTML() {
+		return "", fmt.Errorf("not a web page: unexpected content type %q", page.MediaType)
+	}
+	return extractArticle(string(page.Body), page.URL), nil
+}
+
+// extractArticle finds the main article of a web page with a readability-style algorithm:
+// paragraphs score their parent and grandparent elements by the length and the commas of their text,
+// the scores are weighted by the classes and ids of the elements and by their density of links,
+// and the best element is kept. Its links and images are resolved against the URL of the page,
+// and its HTML is sanitized. The result is empty when no article was found.
+func extractArticle(doc string, base *url.URL) string {
+	root := buildHTMLTree(doc)
+	var candidates []*htmlNode
+	root.walk(func(n *htmlNode) {
+		if n.tok.Type != htmlStartTag || (n.tok.Name != "p" && n.tok.Name != "pre" && n.tok.Name != "td") {
+			return
+		}
+		text := strings.TrimSpace(n.text())
+		if len(text) < articleMinParagraph {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ",")) + min(float64(len(text))/100, 3)
+		for parent, weight := n.parent, 1.0; parent != nil && parent != root && weight >= 0.5; weight /= 2 {
+			if parent.score == 0 {
+				parent.score = parent.classWeight()
+				candidates = append(candidates, parent)
+			}
+			parent.score += score * weight
+			parent = parent.parent
+		}
+	})
+	var best *htmlNode
+	for _, n := range candidates {
+		n.score *= 1 - n.linkDensity()
+		if best == nil || n.score > best.score {
+			best = n
+		}
+	}
+	if best == nil || best.score <= 0 {
+		return ""
+	}
+	var b strings.Builder
+	best.writeHTML(&b, base)
+	return sanitizeHTML(b.String())
+}
+
+// buildHTMLTree builds the document tree of an HTML page, leniently, dropping the elements never part of an article
+func buildHTMLTree(doc string) *htmlNode {
+	root := &htmlNode{tok: htmlToken{Type: htmlStartTag}}
+	current := root
+	skipping := ""
+	for _, tok := range tokenizeHTML(doc) {
+		if skipping != "" {
+			if tok.Type == htmlEndTag && tok.Name == skipping {
+				skipping = ""
+			}
+			continue
+		}
+		switch tok.Type {
+		case htmlText:
+			current.children = append(current.children, &htmlNode{tok: tok, parent: current})
+		case htmlStartTag, htmlSelfClosingTag:
+			if slices.Contains(articleSkippedElements, tok.Name) {
+				if tok.Type == htmlStartTag && !slices.Contains(htmlVoidElements, tok.Name) {
+					skipping = tok.Name
+				}
+				continue
+			}
+			if slices.Contains(articleImplicitlyClosed, tok.Name) && current.tok.Name == tok.Name {
+				current = current.parent
+			}
+			n := &htmlNode{tok: tok, parent: current}
+			current.children = append(current.children, n)
+			if tok.Type == htmlStartTag && !slices.Contains(htmlVoidElements, tok.Name) {
+				current = n
+			}
+		case htmlEndTag:
+			for n := current; n != root; n = n.parent {
+				if n.tok.Name == tok.Name {
+					current = n.parent
+					break
+				}
+			}
+		}
+	}
+	return root
+}
+
+// walk calls fn for the node and its descendants, in document order
+func (n *htmlNode) walk(fn func(*htmlNode)) {
+	fn(n)
+	for _, child := range n.children {
+		child.walk(fn)
+	}
+}
+
+// text returns the text of the node and its descendants
+func (n *htmlNode) text() string {
+	var b strings.Builder
+	n.walk(func(d *htmlNode) {
+		if d.tok.Type == htmlText {
+			b.WriteString(d.tok.Data)
+		}
+	})
+	return b.String()
+}
+
+// linkDensity returns the share of the text of the element which is the text of links
+func (n *htmlNode) linkDensity() float64 {
+	total := len(strings.TrimSpace(n.text()))
+	if total == 0 {
+		return 1
+	}
+	links := 0
+	n.walk(func(d *htmlNode) {
+		if d.tok.Type == htmlStartTag && d.tok.Name == "a" {
+			links += len(strings.TrimSpace(d.text()))
+		}
+	})
+	return min(float64(links)/float64(total), 1)
+}
+
+// classWeight returns the initial score of the element, from its name and the hints of its class and id
+func (n *htmlNode) classWeight() float64 {
+	var weight float64
+	switch n.tok.Name {
+	case "article", "main":
+		// the semantic elements are stronger hints than the classes of their containers
+		weight += 2 * articleClassWeight
+	case "div":
+		weight += 5
+	case "ul", "ol", "dl", "table", "th", "li":
+		weight -= 3
+	}
+	names := strings.ToLower(n.tok.attr("class") + " " + n.tok.attr("id"))
+	if slices.ContainsFunc(articleNegativeHints, func(hint string) bool { return strings.Contains(names, hint) }) {
+		weight -= articleClassWeight
+	}
+	if slices.ContainsFunc(articlePositiveHints, func(hint string) bool { return strings.Contains(names, hint) }) {
+		weight += articleClassWeight
+	}
+	// a zero score marks the elements not scored yet
+	if weight == 0 {
+		weight = 0.1
+	}
+	return weight
+}
+
+// writeHTML writes the HTML of the node and its descendants, resolving the URLs of links and images
+func (n *htmlNode) writeHTML(b *strings.Builder, base *url.URL) {
+	if n.tok.Type == htmlText {
+		b.WriteString(html.EscapeString(n.tok.Data))
+		return
+	}
+	b.WriteString("<" + n.tok.Name)
+	for _, a := range n.tok.Attrs {
+		val := a.Val
+		if a.Key == "href" || a.Key == "src" {
+			val = resolveURL(base, val)
+		}
+		b.WriteString(" " + a.Key + `="` + html.EscapeString(val) + `"`)
+	}
+	b.WriteString(">")
+	if slices.Contains(htmlVoidElements, n.tok.Name) {
+		return
+	}
+	for _, child := range n.children {
+		child.writeHTML(b, base)
+	}
+	b.WriteString("</" + n.tok.Name + ">")
+}
+
+// resolveURL resolves a URL against the URL of the page, the URL being returned as is when it can't be parsed
+func resolveURL(base *url.URL, ref string) string {
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil || base == nil {
+		return ref
+	}
+	return base.ResolveReference(u).String()
+}
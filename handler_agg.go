@@ -2,86 +2,804 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/alnah/go-feedo/internal/database"
 	"github.com/google/uuid"
 )
 
+// goneThreshold is the number of consecutive 404 Not Found or 410 Gone responses
+// after which a feed is considered dead and disabled
+const goneThreshold = 3
+
+// minCollectionDelay is the minimum time between two collections, so that the aggregator
+// doesn't spin when the due feeds are leased by other instances
+const minCollectionDelay = time.Second
+
+// adaptiveSampleSize is the number of recent posts used to measure the publishing frequency of a feed
+const adaptiveSampleSize = 20
+
+// adaptiveMinSample is the minimum number of posts published at different times
+// needed to measure the publishing frequency of a feed
+const adaptiveMinSample = 3
+
+// normalizeBatchSize is the number of stored posts normalized at once by normalizeStoredPosts
+const normalizeBatchSize = 500
+
+// maxBackoff caps the delay before fetching again a feed which keeps failing
+const maxBackoff = 24 * time.Hour
+
+// feedLeaseDuration is how long a claimed feed is reserved for the aggregator instance that claimed it
+// once a worker picks it up, it must be long enough to fetch the feed and store its posts
+const feedLeaseDuration = 5 * time.Minute
+
+// feedFetchTimeout is the time limit of the requests fetching feeds
+const feedFetchTimeout = 10 * time.Second
+
+// contentLeaseDuration is how long a post whose full content is fetched is reserved for the aggregator instance
+// that claimed it, it must be long enough to wait for a request slot on the host and to fetch the web page
+const contentLeaseDuration = 2 * time.Minute
+
+// maxContentAttempts is the number of times the aggregator tries to extract the full content of a post
+const maxContentAttempts = 3
+
+// contentRetryDelay is how long the aggregator waits before trying again to extract the full content of a post,
+// the delay doubling with each attempt
+const contentRetryDelay = 10 * time.Minute
+
+// aggOptions holds the settings of the aggregation loop given on the command line
+type aggOptions struct {
+	// interval is the time between two collections, zero to collect once
+	interval time.Duration
+	// workers is the number of feeds fetched in parallel
+	workers int
+	// batchSize is the number of feeds claimed at each collection
+	batchSize int
+	// perHost is the maximum number of feeds fetched in parallel from the same host
+	perHost int
+	// drainTimeout is how long the in-flight fetches may run after a shutdown signal
+	drainTimeout time.Duration
+	// disableAfter is how long a feed may keep failing before being disabled
+	disableAfter time.Duration
+	// minInterval is the lower bound of the interval adapted to the publishing frequency of a feed
+	minInterval time.Duration
+	// maxInterval is the upper bound of the interval adapted to the publishing frequency of a feed
+	maxInterval time.Duration
+	// maxBodySize is the maximum size in bytes of a feed document
+	maxBodySize int64
+	// downloadEnclosures reports whether the enclosures of new posts are queued and downloaded
+	downloadEnclosures bool
+}
+
+// defaultAggOptions are the settings of a single collection when no flag is given
+var defaultAggOptions = aggOptions{
+	workers:      1,
+	batchSize:    1,
+	perHost:      2,
+	drainTimeout: 30 * time.Second,
+	disableAfter: 7 * 24 * time.Hour,
+	maxInterval:  24 * time.Hour,
+	maxBodySize:  10 << 20,
+}
+
+// parseAggOptions reads the aggregation settings from the arguments of the agg command
+func parseAggOptions(cmd command) (aggOptions, error) {
+	opts := defaultAggOptions
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.IntVar(&opts.workers, "workers", opts.workers, "number of feeds fetched in parallel")
+	fs.IntVar(&opts.batchSize, "batch", 0, "number of feeds claimed at each collection (default to workers)")
+	fs.IntVar(&opts.perHost, "per-host", opts.perHost, "maximum number of feeds fetched in parallel from the same host")
+	fs.DurationVar(&opts.drainTimeout, "drain", opts.drainTimeout, "time given to in-flight fetches on shutdown")
+	fs.DurationVar(&opts.disableAfter, "disable-after", opts.disableAfter, "time after which a failing feed is disabled")
+	fs.DurationVar(&opts.minInterval, "min-interval", opts.minInterval, "lower bound of adaptive intervals")
+	fs.DurationVar(&opts.maxInterval, "max-interval", opts.maxInterval, "upper bound of adaptive intervals")
+	fs.Int64Var(&opts.maxBodySize, "max-size", opts.maxBodySize, "maximum size in bytes of a feed document")
+	fs.BoolVar(&opts.downloadEnclosures, "download-enclosures", false, "download the enclosures of new posts")
+	args, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return aggOptions{}, err
+	}
+	if len(args) > 1 {
+		return aggOptions{}, fmt.Errorf("usage: %v [duration] [flags], see: go-feedo help", cmd.name)
+	}
+	if len(args) == 1 {
+		if opts.interval, err = time.ParseDuration(args[0]); err != nil {
+			return aggOptions{}, fmt.Errorf("invalid duration: %w", err)
+		}
+	}
+	if opts.workers < 1 || opts.perHost < 1 || opts.batchSize < 0 || opts.maxBodySize < 1 {
+		return aggOptions{}, errors.New("workers, batch, per-host and max-size must be positive numbers")
+	}
+	if opts.maxInterval < opts.minInterval {
+		return aggOptions{}, errors.New("max-interval must be greater than min-interval")
+	}
+	if opts.batchSize == 0 {
+		opts.batchSize = opts.workers
+	}
+	return opts, nil
+}
+
 // handlerAgg fetches the RSS feeds, parse them, and print the posts title in the console
 // all in a long-running loop.
+// On SIGINT or SIGTERM, it stops claiming feeds, lets the in-flight fetches finish within the drain timeout,
+// and prints a summary of what was collected during the session.
+// The full contents and the enclosures queued by the collections are fetched in the background,
+// the enclosures being downloaded one at a time.
 func handlerAgg(s *state, cmd command) error {
-	if len(cmd.args) != 1 {
-		log.Printf("Collecting feeds...")
-		if err := scrapeFeeds(s); err != nil {
-			return err
+	opts, err := parseAggOptions(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// the in-flight work outlives the signal until the drain timeout expires
+	workCtx, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelWork()
+	// the drain starts on a signal, or when the collections stop on an error,
+	// so that the background work doesn't hold up the exit for longer than the drain timeout
+	drain := sync.OnceFunc(func() {
+		log.Printf("Shutting down, waiting %s for in-flight fetches...", opts.drainTimeout)
+		time.AfterFunc(opts.drainTimeout, cancelWork)
+	})
+	stopDrain := context.AfterFunc(ctx, drain)
+	defer stopDrain()
+	stats := &aggStats{startedAt: time.Now()}
+	defer stats.print()
+	if err := normalizeStoredPosts(ctx, s); err != nil {
+		return err
+	}
+	// the feeds and the web pages of their posts are fetched through the same host limiter
+	limiter := newHostLimiter(opts.perHost)
+	if opts.interval == 0 {
+		log.Printf("Collecting feeds with %d workers...", opts.workers)
+		err := scrapeFeeds(ctx, workCtx, s, opts, limiter, stats)
+		fetchQueuedContents(ctx, workCtx, s, opts, limiter)
+		if opts.downloadEnclosures {
+			downloadQueuedEnclosures(ctx, workCtx, s)
 		}
-		return nil
+		return err
 	}
-	timeBtwReqs, err := time.ParseDuration(cmd.args[0])
-	if err != nil {
-		return fmt.Errorf("invalid duration: %w", err)
+	log.Printf("Collecting feeds every %s with %d workers...", opts.interval.String(), opts.workers)
+	// the queues are processed apart from the collections, which slow web pages and long downloads would hold up
+	queueCtx, stopQueues := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer func() {
+		stopQueues()
+		drain()
+		wg.Wait()
+	}()
+	contentsQueued := make(chan struct{}, 1)
+	processQueue(queueCtx, &wg, opts.interval, contentsQueued, func() {
+		fetchQueuedContents(queueCtx, workCtx, s, opts, limiter)
+	})
+	enclosuresQueued := make(chan struct{}, 1)
+	if opts.downloadEnclosures {
+		processQueue(queueCtx, &wg, opts.interval, enclosuresQueued, func() {
+			downloadQueuedEnclosures(queueCtx, workCtx, s)
+		})
 	}
-	log.Printf("Collecting feeds every %s...", timeBtwReqs.String())
-	ticker := time.NewTicker(timeBtwReqs)
-	for ; ; <-ticker.C {
-		if err := scrapeFeeds(s); err != nil {
+	for {
+		if err := scrapeFeeds(ctx, workCtx, s, opts, limiter, stats); err != nil {
 			return err
 		}
+		notifyQueue(contentsQueued)
+		notifyQueue(enclosuresQueued)
+		wait, err := nextCollectionDelay(ctx, s, opts)
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
 	}
 }
 
-// scrapeFeeds goes to the next feed to fetch, mark it as fetched, fetch the feed data
-// using its URL, create the posts for that feed into the database,
-// and print the post titles in the console
-func scrapeFeeds(s *state) error {
-	ctx := context.Background()
-	nextFeedToFetch, err := s.dbQr.GetNextFeedToFetch(ctx)
-	if err != nil {
-		return fmt.Errorf("couldn't get next feed to fetch: %w", err)
+// processQueue processes a queue filled by the collections in a goroutine added to wg, each time the collections
+// notify it, and at least once per interval for the retries, until ctx is done
+func processQueue(ctx context.Context, wg *sync.WaitGroup, interval time.Duration, queued <-chan struct{},
+	process func(),
+) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			process()
+			select {
+			case <-ctx.Done():
+				return
+			case <-queued:
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
+
+// notifyQueue tells the goroutine processing a queue that items were added to it, without waiting
+// when it's busy, since it processes the whole queue anyway
+func notifyQueue(queued chan<- struct{}) {
+	select {
+	case queued <- struct{}{}:
+	default:
 	}
-	log.Println("Found a feed to fetch!")
-	err = s.dbQr.MarkFeedFetched(ctx, database.MarkFeedFetchedParams{
-		ID:        nextFeedToFetch.ID,
-		UpdatedAt: time.Now(),
+}
+
+// nextCollectionDelay returns how long to wait before the next collection: until the next feed is due,
+// and at most the collection interval, so that the feeds with a custom interval shorter
+// than the collection interval are fetched in time
+func nextCollectionDelay(ctx context.Context, s *state, opts aggOptions) (time.Duration, error) {
+	nextFetchAt, err := s.dbQr.GetNextFetchAt(ctx, time.Now().UTC())
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return opts.interval, nil
+	case err != nil:
+		if ctx.Err() != nil {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("couldn't get next fetch time: %w", err)
+	}
+	// overdue feeds, left out of a full batch, are collected right away, and so are the feeds never scheduled:
+	// the new and revived ones, and the ones whose interval was just changed
+	if !nextFetchAt.Valid {
+		return minCollectionDelay, nil
+	}
+	return min(max(time.Until(nextFetchAt.Time), minCollectionDelay), opts.interval), nil
+}
+
+// aggStats counts what was collected during an agg session, it's safe for concurrent use
+type aggStats struct {
+	startedAt    time.Time
+	feeds        atomic.Int64
+	notModified  atomic.Int64
+	failed       atomic.Int64
+	postsCreated atomic.Int64
+	postsUpdated atomic.Int64
+}
+
+// print logs the summary of the session
+func (st *aggStats) print() {
+	log.Printf("Session summary: %d feeds fetched (%d not modified, %d failed), %d new posts, %d updated posts in %s",
+		st.feeds.Load(), st.notModified.Load(), st.failed.Load(),
+		st.postsCreated.Load(), st.postsUpdated.Load(), time.Since(st.startedAt).Round(time.Second))
+}
+
+// backoffDelay returns how long to wait before fetching again a feed which failed errorCount times in a row,
+// the delay doubles with each failure, starting from the collection interval, up to maxBackoff
+func backoffDelay(interval time.Duration, errorCount int32) time.Duration {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	delay := interval
+	for i := int32(1); i < errorCount && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
+
+// scrapeFeeds claims a batch of the feeds fetched the longest time ago by leasing them,
+// and scrape them in parallel with a pool of workers, starting with the oldest ones.
+// The lease keeps other aggregator instances away from the claimed feeds until they're marked as fetched,
+// or until it expires when the instance crashed in the meantime.
+// Once ctx is done no more feed is handed to the workers, and the leases of the remaining ones
+// are released, while the in-flight fetches go on with workCtx.
+// It waits for the whole batch to be scraped, the failures being logged and recorded on the feeds
+// without stopping the collection of the other ones.
+func scrapeFeeds(ctx, workCtx context.Context, s *state, opts aggOptions, limiter *hostLimiter, stats *aggStats) error {
+	now := time.Now().UTC()
+	feeds, err := s.dbQr.ClaimFeedsToFetch(ctx, database.ClaimFeedsToFetchParams{
+		UpdatedAt:      now,
+		LeaseExpiresAt: sql.NullTime{Time: now.Add(feedLease(opts)), Valid: true},
+		Limit:          int32(opts.batchSize),
 	})
 	if err != nil {
-		return fmt.Errorf("couldn't mark feed as fetched: %w", err)
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("couldn't claim feeds to fetch: %w", err)
+	}
+	// the claim doesn't return the feeds in order
+	slices.SortStableFunc(feeds, func(a, b database.Feed) int {
+		return compareNullTime(a.LastFetchedAt, b.LastFetchedAt)
+	})
+	log.Printf("Found %d feeds to fetch!", len(feeds))
+	jobs := make(chan database.Feed)
+	var wg sync.WaitGroup
+	for range opts.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for feed := range jobs {
+				release := limiter.acquire(feedHost(feed.Url))
+				err := scrapeFeed(workCtx, s, feed, opts, stats)
+				release()
+				// the feed is marked as fetched even when scraping failed, to release its lease
+				if markErr := s.dbQr.MarkFeedFetched(workCtx, database.MarkFeedFetchedParams{
+					ID:        feed.ID,
+					UpdatedAt: time.Now().UTC(),
+				}); markErr != nil {
+					err = errors.Join(err, fmt.Errorf("couldn't mark feed as fetched: %w", markErr))
+				}
+				if err != nil {
+					stats.failed.Add(1)
+					log.Printf("couldn't scrape feed %s: %v", feed.Name, err)
+				}
+			}
+		}()
+	}
+dispatch:
+	for i, feed := range feeds {
+		select {
+		case jobs <- feed:
+		case <-ctx.Done():
+			releaseFeedLeases(workCtx, s, feeds[i:])
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return nil
+}
+
+// feedLease returns how long a batch of claimed feeds is reserved. The feeds of a batch larger than the pool
+// of workers, or sharing a host, wait for the fetches before them, each one taking up to feedFetchTimeout,
+// and their leases must not expire meanwhile, lest another aggregator instance claims them too.
+func feedLease(opts aggOptions) time.Duration {
+	parallel := min(opts.workers, opts.perHost)
+	rounds := (opts.batchSize + parallel - 1) / parallel
+	return feedLeaseDuration + time.Duration(rounds)*feedFetchTimeout
+}
+
+// releaseFeedLeases gives back the claimed feeds which weren't scraped, so that
+// another aggregator instance can fetch them without waiting for the leases to expire
+func releaseFeedLeases(ctx context.Context, s *state, feeds []database.Feed) {
+	for _, feed := range feeds {
+		err := s.dbQr.ReleaseFeedLease(ctx, database.ReleaseFeedLeaseParams{
+			ID:        feed.ID,
+			UpdatedAt: time.Now().UTC(),
+		})
+		if err != nil {
+			log.Printf("couldn't release lease of feed %s: %v", feed.Name, err)
+		}
+	}
+}
+
+// compareNullTime orders null times first, then times in chronological order
+func compareNullTime(a, b sql.NullTime) int {
+	switch {
+	case !a.Valid && !b.Valid:
+		return 0
+	case !a.Valid:
+		return -1
+	case !b.Valid:
+		return 1
+	default:
+		return a.Time.Compare(b.Time)
+	}
+}
+
+// hostLimiter caps the number of concurrent requests sent to the same host,
+// so that a batch full of feeds from one publisher doesn't hammer its server
+type hostLimiter struct {
+	mu    sync.Mutex
+	limit int
+	slots map[string]chan struct{}
+}
+
+// newHostLimiter creates a host limiter allowing limit concurrent requests per host
+func newHostLimiter(limit int) *hostLimiter {
+	return &hostLimiter{limit: limit, slots: make(map[string]chan struct{})}
+}
+
+// acquire blocks until a request slot is available for the host, and returns
+// the function releasing the slot
+func (l *hostLimiter) acquire(host string) (release func()) {
+	l.mu.Lock()
+	slots, ok := l.slots[host]
+	if !ok {
+		slots = make(chan struct{}, l.limit)
+		l.slots[host] = slots
+	}
+	l.mu.Unlock()
+	slots <- struct{}{}
+	return func() { <-slots }
+}
+
+// feedHost returns the host of the feed URL, or the URL itself when it can't be parsed
+func feedHost(feedURL string) string {
+	u, err := url.Parse(feedURL)
+	if err != nil || u.Host == "" {
+		return feedURL
 	}
-	feedData, err := fetchFeed(ctx, nextFeedToFetch.Url)
+	return strings.ToLower(u.Host)
+}
+
+// normalizeStoredPosts normalizes the text of the posts stored before feeds were normalized, and rehashes it,
+// so that the next collection doesn't take every post whose text holds an entity for an edited one.
+// The stored text was parsed from the feeds as the fetched one is, so both normalize into the same text.
+func normalizeStoredPosts(ctx context.Context, s *state) error {
+	normalized := 0
+	for {
+		posts, err := s.dbQr.GetPostsToNormalize(ctx, normalizeBatchSize)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("couldn't get posts to normalize: %w", err)
+		}
+		if len(posts) == 0 {
+			break
+		}
+		for _, post := range posts {
+			title := normalizeText(post.Title)
+			description := normalizeHTML(post.Description)
+			if err = s.dbQr.NormalizePostText(ctx, database.NormalizePostTextParams{
+				ID:                   post.ID,
+				Title:                title,
+				Description:          description,
+				SanitizedDescription: sanitizeHTML(description),
+				ContentHash:          contentHash(title, description),
+			}); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return fmt.Errorf("couldn't normalize post %q: %w", post.Title, err)
+			}
+		}
+		normalized += len(posts)
+	}
+	if normalized > 0 {
+		log.Printf("Normalized the text of %d stored posts", normalized)
+	}
+	return nil
+}
+
+// contentHash fingerprints the content of a post to detect when the publisher edits it.
+// Whitespace runs count as single spaces, so that reindenting the markup of a description isn't taken for an edit.
+func contentHash(title, description string) string {
+	sum := sha256.Sum256([]byte(collapseASCIISpace(title) + "\n" + collapseASCIISpace(description)))
+	return hex.EncodeToString(sum[:])
+}
+
+// collapseASCIISpace trims the ASCII whitespace of the text and collapses its runs into single spaces
+func collapseASCIISpace(s string) string {
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool { return strings.ContainsRune(" \t\n\r\f\v", r) }), " ")
+}
+
+// scrapeFeed fetches the feed data using its URL and the validators of the previous fetch,
+// create or update the posts for that feed into the database, and print the post titles in the console.
+// A failed fetch is recorded on the feed, which is then fetched again after an exponential backoff.
+func scrapeFeed(ctx context.Context, s *state, feed database.Feed, opts aggOptions, stats *aggStats) error {
+	feedRes, err := fetchFeed(ctx, feed, opts.maxBodySize)
 	if err != nil {
-		return fmt.Errorf("couldn't fetch feed: %w", err)
-	}
-	for _, item := range feedData.Channel.Item {
-		publishedAt := sql.NullTime{}
-		if t, err := time.Parse(time.RFC1123Z, item.PubDate); err == nil {
-			publishedAt = sql.NullTime{
-				Time:  t,
-				Valid: true,
+		err = fmt.Errorf("couldn't fetch feed: %w", err)
+		return errors.Join(err, recordFeedFailure(ctx, s, feed, opts, err))
+	}
+	if feedRes.ETag != feed.Etag || feedRes.LastModified != feed.LastModified {
+		err = s.dbQr.UpdateFeedValidators(ctx, database.UpdateFeedValidatorsParams{
+			ID:           feed.ID,
+			UpdatedAt:    time.Now().UTC(),
+			Etag:         feedRes.ETag,
+			LastModified: feedRes.LastModified,
+		})
+		if err != nil {
+			return fmt.Errorf("couldn't update feed validators: %w", err)
+		}
+	}
+	stats.feeds.Add(1)
+	// a feed not modified since the last fetch doesn't repeat its polling hints, the stored ones still apply
+	sched := feedPollingSchedule(feed)
+	if feedRes.NotModified {
+		stats.notModified.Add(1)
+		log.Printf("Feed %s not modified since last fetch", feed.Name)
+	} else {
+		sched = feedRes.Feed.Channel.RSSPollingHints.schedule()
+		storePosts(ctx, s, feed, feedRes.Feed.Channel.Item, opts, stats)
+		log.Printf("Feed %s collected, %v posts found", feed.Name, len(feedRes.Feed.Channel.Item))
+	}
+	if err = recordFeedSuccess(ctx, s, feed, feedRes.StatusCode, sched, opts); err != nil {
+		return err
+	}
+	if feedRes.PermanentURL != "" {
+		return moveFeed(ctx, s, feed, feedRes.PermanentURL)
+	}
+	return nil
+}
+
+// storePosts creates the new items of the feed as posts, and updates the posts whose content changed,
+// along with their enclosures. These posts are queued for the extraction of their full content
+// from their web page when the feed is in full-content mode, unless the feed gives it.
+func storePosts(ctx context.Context, s *state, feed database.Feed, items []RSSItem, opts aggOptions,
+	stats *aggStats,
+) {
+	fetchedAt := time.Now().UTC()
+	for _, item := range items {
+		guid := item.identifier()
+		if guid == "" {
+			log.Printf("couldn't create post: item %q has neither a guid nor a link", item.Title)
+			continue
+		}
+		// items without a parsable date are considered published when they were collected
+		publishedAt := sql.NullTime{Time: fetchedAt, Valid: true}
+		if t, ok := parsePubDate(item.PubDate); ok {
+			publishedAt.Time = t
+		} else if item.PubDate != "" {
+			log.Printf("couldn't parse publication date %q of item %q, using the fetch time", item.PubDate, item.Title)
+		}
+		// posts identified by their URL, such as the ones stored before guids were, adopt the guid of their item
+		if item.Link != "" && guid != item.Link {
+			if err := s.dbQr.AdoptPostGUID(ctx, database.AdoptPostGUIDParams{
+				Guid:   guid,
+				FeedID: feed.ID,
+				Url:    item.Link,
+			}); err != nil {
+				log.Printf("couldn't match post %q by URL: %v", item.Title, err)
 			}
 		}
-		_, err = s.dbQr.CreatePost(context.Background(), database.CreatePostParams{
-			ID:          uuid.New(),
-			CreatedAt:   time.Now().UTC(),
-			UpdatedAt:   time.Now().UTC(),
-			FeedID:      nextFeedToFetch.ID,
-			Title:       item.Title,
-			Description: item.Description,
-			Url:         item.Link,
-			PublishedAt: publishedAt,
+		content := sanitizeHTML(item.Content)
+		// the full content given by the feed spares the extraction from the web page
+		var contentRequestedAt sql.NullTime
+		if feed.FullContent && content == "" && item.Link != "" {
+			contentRequestedAt = sql.NullTime{Time: fetchedAt, Valid: true}
+		}
+		post, err := s.dbQr.CreatePost(ctx, database.CreatePostParams{
+			ID:                   uuid.New(),
+			CreatedAt:            time.Now().UTC(),
+			UpdatedAt:            time.Now().UTC(),
+			FeedID:               feed.ID,
+			Title:                item.Title,
+			Description:          item.Description,
+			Url:                  item.Link,
+			PublishedAt:          publishedAt,
+			Guid:                 guid,
+			ContentHash:          contentHash(item.Title, item.Description),
+			SanitizedDescription: sanitizeHTML(item.Description),
+			Content:              content,
+			Author:               item.author(),
+			Categories:           item.Categories,
+			CommentsUrl:          item.Comments,
+			ImageUrl:             item.image(),
+			ContentRequestedAt:   contentRequestedAt,
 		})
 		if err != nil {
-			if strings.Contains(err.Error(), "duplicate key value") {
+			// the post is already stored for this feed, and its content didn't change
+			if errors.Is(err, sql.ErrNoRows) {
 				continue
 			}
 			log.Printf("couldn't create post: %v", err)
 			continue
 		}
+		storeEnclosures(ctx, s, post, item, opts)
+		if post.Revision > 1 {
+			stats.postsUpdated.Add(1)
+			log.Printf("Post %q updated (revision %d)", post.Title, post.Revision)
+			continue
+		}
+		stats.postsCreated.Add(1)
+	}
+}
+
+// fetchQueuedContents extracts the main article of the web pages of the posts queued by the collections,
+// and stores it as their content, with a pool of workers until the queue is empty or ctx is done.
+// Each post is claimed with a lease, which keeps the other aggregator instances away from it,
+// and the pages are fetched through the host limiter, since they're often served by the host of the feed.
+// The in-flight fetches go on with workCtx, and the failures are tried again after a backoff,
+// up to maxContentAttempts times, the posts keeping their description meanwhile.
+func fetchQueuedContents(ctx, workCtx context.Context, s *state, opts aggOptions, limiter *hostLimiter) {
+	var wg sync.WaitGroup
+	for range opts.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				now := time.Now().UTC()
+				post, err := s.dbQr.ClaimPostToFetchContent(ctx, database.ClaimPostToFetchContentParams{
+					LeaseExpiresAt: sql.NullTime{Time: now.Add(contentLeaseDuration), Valid: true},
+					MaxAttempts:    maxContentAttempts,
+					Now:            now,
+				})
+				if errors.Is(err, sql.ErrNoRows) {
+					return
+				}
+				if err != nil {
+					if ctx.Err() == nil {
+						log.Printf("couldn't claim post to fetch full content: %v", err)
+					}
+					return
+				}
+				release := limiter.acquire(feedHost(post.Url))
+				err = storePostContent(workCtx, s, post, opts)
+				release()
+				if err != nil {
+					log.Printf("couldn't store full content of post %q (attempt %d of %d): %v",
+						post.Title, post.ContentAttempts, maxContentAttempts, err)
+					// the lease is kept until the next attempt is due, unless the fetch was interrupted by a shutdown
+					var leaseExpiresAt sql.NullTime
+					if workCtx.Err() == nil {
+						retryAt := time.Now().UTC().Add(backoffDelay(contentRetryDelay, post.ContentAttempts))
+						leaseExpiresAt = sql.NullTime{Time: retryAt, Valid: true}
+					}
+					if releaseErr := s.dbQr.ReleasePostContentLease(context.WithoutCancel(workCtx),
+						database.ReleasePostContentLeaseParams{ID: post.ID, ContentLeaseExpiresAt: leaseExpiresAt},
+					); releaseErr != nil {
+						log.Printf("couldn't release lease of post %q: %v", post.Title, releaseErr)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// storePostContent extracts the main article of the web page of the post, and stores it as the post content
+func storePostContent(ctx context.Context, s *state, post database.Post, opts aggOptions) error {
+	content, err := fetchArticle(ctx, post.Url, opts.maxBodySize)
+	if err != nil {
+		return fmt.Errorf("couldn't fetch full content: %w", err)
+	}
+	if content == "" {
+		return fmt.Errorf("couldn't find the article in %s", post.Url)
+	}
+	if err = s.dbQr.UpdatePostContent(ctx, database.UpdatePostContentParams{
+		ID:        post.ID,
+		UpdatedAt: time.Now().UTC(),
+		Content:   content,
+	}); err != nil {
+		return fmt.Errorf("couldn't update post content: %w", err)
+	}
+	return nil
+}
+
+// recordFeedSuccess clears the failures of the feed, and schedules its next fetch.
+// A custom interval set on the feed takes precedence, otherwise the interval adapted to its publishing
+// frequency is used, and the polling hints it declares are respected and stored.
+// The schedule starts from the claim time, stored in feed.UpdatedAt, to stay in phase with the collections.
+func recordFeedSuccess(ctx context.Context, s *state, feed database.Feed, statusCode int,
+	sched pollingSchedule, opts aggOptions,
+) error {
+	adaptive, err := adaptiveInterval(ctx, s, feed, opts)
+	if err != nil {
+		return err
+	}
+	var next time.Time
+	if feed.CustomIntervalSeconds > 0 {
+		next = feed.UpdatedAt.Add(time.Duration(feed.CustomIntervalSeconds) * time.Second)
+	} else {
+		next = sched.nextFetchAt(feed.UpdatedAt, max(opts.interval, adaptive))
+	}
+	err = s.dbQr.RecordFeedSuccess(ctx, database.RecordFeedSuccessParams{
+		ID:                      feed.ID,
+		UpdatedAt:               time.Now().UTC(),
+		LastStatusCode:          int32(statusCode),
+		NextFetchAt:             sql.NullTime{Time: next, Valid: true},
+		AdaptiveIntervalSeconds: int32(adaptive / time.Second),
+		PollMinDelaySeconds:     int32(sched.minDelay / time.Second),
+		PollSkipHours:           sched.skipHours,
+		PollSkipDays:            sched.skipDays,
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't record feed success: %w", err)
+	}
+	return nil
+}
+
+// adaptiveInterval computes the fetch interval of the feed from the average time between its recent posts,
+// bounded by the minimum and maximum intervals, zero when the feed has too few posts to tell
+func adaptiveInterval(ctx context.Context, s *state, feed database.Feed, opts aggOptions) (time.Duration, error) {
+	pubStats, err := s.dbQr.GetFeedPublishingStats(ctx, database.GetFeedPublishingStatsParams{
+		FeedID: feed.ID,
+		Limit:  adaptiveSampleSize,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("couldn't get feed publishing stats: %w", err)
+	}
+	if pubStats.PostCount < adaptiveMinSample {
+		return 0, nil
+	}
+	average := time.Duration(pubStats.SpanSeconds) * time.Second / time.Duration(pubStats.PostCount-1)
+	return min(max(average, opts.minInterval, opts.interval), opts.maxInterval), nil
+}
+
+// moveFeed updates the URL of a feed which was permanently redirected.
+// When a feed already exists with the new URL, both are merged into the existing one:
+// the follows and the posts are moved to it, and the redirected feed is deleted.
+func moveFeed(ctx context.Context, s *state, feed database.Feed, newURL string) error {
+	tx, err := s.dbCon.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("couldn't begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	qtx := s.dbQr.WithTx(tx)
+	now := time.Now().UTC()
+	target, err := qtx.GetFeedByURL(ctx, newURL)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		err = qtx.UpdateFeedURL(ctx, database.UpdateFeedURLParams{ID: feed.ID, UpdatedAt: now, Url: newURL})
+		if err != nil {
+			return fmt.Errorf("couldn't update feed URL: %w", err)
+		}
+		log.Printf("Feed %s moved permanently to %s", feed.Name, newURL)
+	case err != nil:
+		return fmt.Errorf("couldn't get feed: %w", err)
+	default:
+		err = qtx.MoveFeedFollows(ctx, database.MoveFeedFollowsParams{
+			UpdatedAt: now,
+			NewFeedID: target.ID,
+			OldFeedID: feed.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("couldn't move feed follows: %w", err)
+		}
+		err = qtx.MovePosts(ctx, database.MovePostsParams{UpdatedAt: now, NewFeedID: target.ID, OldFeedID: feed.ID})
+		if err != nil {
+			return fmt.Errorf("couldn't move posts: %w", err)
+		}
+		if err = qtx.DeleteFeed(ctx, feed.ID); err != nil {
+			return fmt.Errorf("couldn't delete feed: %w", err)
+		}
+		log.Printf("Feed %s moved permanently to %s, merged into feed %s", feed.Name, newURL, target.Name)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("couldn't commit transaction: %w", err)
+	}
+	return nil
+}
+
+// recordFeedFailure stores the fetch error on the feed, and delays its next fetch.
+// The feed is disabled when it keeps answering 404 or 410, or when it has been failing for too long.
+func recordFeedFailure(ctx context.Context, s *state, feed database.Feed, opts aggOptions, fetchErr error) error {
+	var statusCode int32
+	var stErr *statusError
+	if errors.As(fetchErr, &stErr) {
+		statusCode = int32(stErr.StatusCode)
+	}
+	now := time.Now().UTC()
+	errorCount := feed.ErrorCount + 1
+	failingSince := now
+	if feed.FailingSince.Valid {
+		failingSince = feed.FailingSince.Time
+	}
+	disabledAt := sql.NullTime{}
+	// only the consecutive 404 and 410 responses tell the feed is gone, not the other failures between them
+	var goneCount int32
+	if statusCode == http.StatusNotFound || statusCode == http.StatusGone {
+		goneCount = feed.GoneCount + 1
+	}
+	if goneCount >= goneThreshold || now.Sub(failingSince) >= opts.disableAfter {
+		disabledAt = sql.NullTime{Time: now, Valid: true}
+		log.Printf("Feed %s failed %d times in a row, it's now disabled", feed.Name, errorCount)
+	}
+	delay := backoffDelay(opts.interval, errorCount)
+	if !disabledAt.Valid {
+		log.Printf("Feed %s failed %d times in a row, next fetch in %s", feed.Name, errorCount, delay)
+	}
+	err := s.dbQr.RecordFeedFailure(ctx, database.RecordFeedFailureParams{
+		ID:             feed.ID,
+		UpdatedAt:      now,
+		LastError:      fetchErr.Error(),
+		LastStatusCode: statusCode,
+		NextFetchAt:    sql.NullTime{Time: now.Add(delay), Valid: true},
+		DisabledAt:     disabledAt,
+		GoneCount:      goneCount,
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't record feed failure: %w", err)
 	}
-	log.Printf("Feed %s collected, %v posts found", nextFeedToFetch.Name, len(feedData.Channel.Item))
 	return nil
 }
@@ -2,17 +2,63 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/alnah/go-feedo/internal/database"
+	"github.com/google/uuid"
 )
 
-// handlerBrowse browses the posts for the current user using its followed feeds
+// postJSON is a post printed by browse --json, for the front-ends built on go-feedo
+type postJSON struct {
+	ID          uuid.UUID       `json:"id"`
+	FeedName    string          `json:"feed_name"`
+	Title       string          `json:"title"`
+	URL         string          `json:"url"`
+	PublishedAt *time.Time      `json:"published_at,omitempty"`
+	Revision    int32           `json:"revision"`
+	Author      string          `json:"author,omitempty"`
+	Categories  []string        `json:"categories"`
+	ImageURL    string          `json:"image_url,omitempty"`
+	Description string          `json:"description"`
+	Content     string          `json:"content,omitempty"`
+	CommentsURL string          `json:"comments_url,omitempty"`
+	Enclosures  []enclosureJSON `json:"enclosures"`
+}
+
+// enclosureJSON is an enclosure of a post printed by browse --json
+type enclosureJSON struct {
+	URL             string `json:"url"`
+	MimeType        string `json:"mime_type,omitempty"`
+	Length          int64  `json:"length,omitempty"`
+	DurationSeconds int32  `json:"duration_seconds,omitempty"`
+	Episode         int32  `json:"episode,omitempty"`
+	Season          int32  `json:"season,omitempty"`
+	FilePath        string `json:"file_path,omitempty"`
+}
+
+// handlerBrowse browses the posts for the current user using its followed feeds,
+// showing their full content instead of their description with the --full flag,
+// or printing them as JSON with the --json flag
 func handlerBrowse(s *state, cmd command, user database.User) error {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	full := fs.Bool("full", false, "show the full content of posts when it was collected")
+	asJSON := fs.Bool("json", false, "print the posts as JSON")
+	args, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("usage: %v [--full] [--json] [limit]", cmd.name)
+	}
 	limit := 2
-	if len(cmd.args) == 1 {
-		if specifiedLimit, err := strconv.Atoi(cmd.args[0]); err == nil {
+	if len(args) == 1 {
+		if specifiedLimit, err := strconv.Atoi(args[0]); err == nil {
 			limit = specifiedLimit
 		} else {
 			return fmt.Errorf("invalid limit: %w", err)
@@ -25,13 +71,129 @@ func handlerBrowse(s *state, cmd command, user database.User) error {
 	if err != nil {
 		return fmt.Errorf("couldn't get posts for user: %w", err)
 	}
+	if *asJSON {
+		return printPostsJSON(s, posts)
+	}
 	fmt.Printf("Found %d posts for user %s:\n", len(posts), user.Name)
+	styled := isTerminal(os.Stdout)
 	for _, post := range posts {
 		fmt.Printf("%s from %s\n", post.PublishedAt.Time.Format("Mon Jan 2"), post.FeedName)
-		fmt.Printf("--- %s ---\n", post.Title)
-		fmt.Printf("    %v\n", post.Description)
+		if post.Revision > 1 {
+			fmt.Printf("--- %s --- (updated)\n", post.Title)
+		} else {
+			fmt.Printf("--- %s ---\n", post.Title)
+		}
+		if post.Author != "" {
+			fmt.Printf("By %s\n", post.Author)
+		}
+		if len(post.Categories) > 0 {
+			fmt.Printf("Categories: %s\n", strings.Join(post.Categories, ", "))
+		}
+		// posts stored before descriptions were sanitized are sanitized when they're browsed
+		description := post.SanitizedDescription
+		if description == "" {
+			description = sanitizeHTML(post.Description)
+		}
+		if *full && post.Content != "" {
+			description = post.Content
+		}
+		for _, line := range strings.Split(renderHTML(description, styled), "\n") {
+			fmt.Printf("    %s\n", line)
+		}
 		fmt.Printf("Link: %s\n", post.Url)
+		if post.ImageUrl != "" {
+			fmt.Printf("Image: %s\n", post.ImageUrl)
+		}
+		if post.CommentsUrl != "" {
+			fmt.Printf("Comments: %s\n", post.CommentsUrl)
+		}
+		enclosures, err := s.dbQr.GetEnclosuresForPost(context.Background(), post.ID)
+		if err != nil {
+			return fmt.Errorf("couldn't get enclosures of post: %w", err)
+		}
+		for _, enclosure := range enclosures {
+			printEnclosure(enclosure)
+		}
+		if len(enclosures) > 0 {
+			fmt.Printf("Download: go-feedo download %s\n", post.ID)
+		}
 		fmt.Println("=====================================")
 	}
 	return nil
 }
+
+// printPostsJSON prints the posts and their enclosures as a JSON array
+func printPostsJSON(s *state, posts []database.GetPostsForUserRow) error {
+	items := make([]postJSON, 0, len(posts))
+	for _, post := range posts {
+		enclosures, err := s.dbQr.GetEnclosuresForPost(context.Background(), post.ID)
+		if err != nil {
+			return fmt.Errorf("couldn't get enclosures of post: %w", err)
+		}
+		item := postJSON{
+			ID:          post.ID,
+			FeedName:    post.FeedName,
+			Title:       post.Title,
+			URL:         post.Url,
+			Revision:    post.Revision,
+			Author:      post.Author,
+			Categories:  post.Categories,
+			ImageURL:    post.ImageUrl,
+			Description: post.SanitizedDescription,
+			Content:     post.Content,
+			CommentsURL: post.CommentsUrl,
+			Enclosures:  make([]enclosureJSON, 0, len(enclosures)),
+		}
+		if post.PublishedAt.Valid {
+			item.PublishedAt = &post.PublishedAt.Time
+		}
+		if item.Description == "" {
+			item.Description = sanitizeHTML(post.Description)
+		}
+		for _, enclosure := range enclosures {
+			item.Enclosures = append(item.Enclosures, enclosureJSON{
+				URL:             enclosure.Url,
+				MimeType:        enclosure.MimeType,
+				Length:          enclosure.Length,
+				DurationSeconds: enclosure.DurationSeconds,
+				Episode:         enclosure.Episode,
+				Season:          enclosure.Season,
+				FilePath:        enclosure.FilePath,
+			})
+		}
+		items = append(items, item)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(items); err != nil {
+		return fmt.Errorf("couldn't encode posts: %w", err)
+	}
+	return nil
+}
+
+func printEnclosure(enclosure database.Enclosure) {
+	fmt.Printf("Enclosure: %s", enclosure.Url)
+	var details []string
+	if enclosure.Season > 0 {
+		details = append(details, fmt.Sprintf("season %d", enclosure.Season))
+	}
+	if enclosure.Episode > 0 {
+		details = append(details, fmt.Sprintf("episode %d", enclosure.Episode))
+	}
+	if enclosure.MimeType != "" {
+		details = append(details, enclosure.MimeType)
+	}
+	if enclosure.DurationSeconds > 0 {
+		details = append(details, (time.Duration(enclosure.DurationSeconds) * time.Second).String())
+	}
+	if enclosure.Length > 0 {
+		details = append(details, fmt.Sprintf("%.1f MB", float64(enclosure.Length)/1e6))
+	}
+	if enclosure.DownloadedAt.Valid {
+		details = append(details, "downloaded to "+enclosure.FilePath)
+	}
+	if len(details) > 0 {
+		fmt.Printf(" (%s)", strings.Join(details, ", "))
+	}
+	fmt.Println()
+}
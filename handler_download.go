@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/alnah/go-feedo/internal/database"
+	"github.com/google/uuid"
+)
+
+// handlerDownload downloads the enclosures of a post, given by its ID or its URL, into the download directory.
+// An interrupted download resumes where it stopped the next time.
+func handlerDownload(s *state, cmd command) error {
+	if len(cmd.args) != 1 {
+		return fmt.Errorf("usage: %v <post_id|post_url>", cmd.name)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var enclosures []database.Enclosure
+	var err error
+	if postID, parseErr := uuid.Parse(cmd.args[0]); parseErr == nil {
+		enclosures, err = s.dbQr.GetEnclosuresForPost(ctx, postID)
+	} else {
+		enclosures, err = s.dbQr.GetEnclosuresForPostURL(ctx, cmd.args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't get enclosures: %w", err)
+	}
+	if len(enclosures) == 0 {
+		return errors.New("no enclosure found for this post")
+	}
+	var errs []error
+	for _, enclosure := range enclosures {
+		// the claim keeps the aggregator from downloading the enclosure into the same file meanwhile
+		now := time.Now().UTC()
+		claimed, err := s.dbQr.ClaimEnclosure(ctx, database.ClaimEnclosureParams{
+			ID:             enclosure.ID,
+			UpdatedAt:      now,
+			LeaseExpiresAt: sql.NullTime{Time: now.Add(enclosureLeaseDuration), Valid: true},
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			errs = append(errs, fmt.Errorf("couldn't download %s: it's leased by an aggregator, "+
+				"which is downloading it or will try again later", enclosure.Url))
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("couldn't claim enclosure: %w", err))
+			continue
+		}
+		fmt.Printf("Downloading %s...\n", claimed.Url)
+		// a failed download can be tried again right away, with this command or by the aggregator
+		filePath, err := downloadEnclosure(ctx, s, claimed, 0)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("couldn't download %s: %w", enclosure.Url, err))
+			continue
+		}
+		fmt.Printf("Saved to %s\n", filePath)
+	}
+	return errors.Join(errs...)
+}
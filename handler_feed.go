@@ -2,20 +2,51 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/alnah/go-feedo/internal/database"
 	"github.com/google/uuid"
 )
 
-// handlerAddFeed adds a feed to the feeds table, and follows it for the current user who added the feed
+// handlerAddFeed adds a feed to the feeds table, and follows it for the current user who added the feed.
+// The URL may be the one of a website, whose feed is then discovered, and when the website has several
+// feeds they're listed for the user to add one of them.
 func handlerAddFeed(s *state, cmd command, user database.User) error {
 	if len(cmd.args) != 2 {
 		return fmt.Errorf("usage: %v <name> <url>", cmd.name)
 	}
 	name := cmd.args[0]
-	url := cmd.args[1]
+	candidates, err := discoverFeeds(context.Background(), cmd.args[1])
+	switch {
+	case errors.Is(err, errPageUnavailable):
+		// a feed whose host is briefly down is added as is, the aggregator fetching it later
+		fmt.Printf("Couldn't check %s, adding it as is: %v\n", cmd.args[1], err)
+		candidates = []feedCandidate{{URL: cmd.args[1]}}
+	case err != nil:
+		return fmt.Errorf("couldn't discover feed: %w", err)
+	}
+	if len(candidates) == 0 {
+		return fmt.Errorf("couldn't discover feed: no feed found at %s", cmd.args[1])
+	}
+	if len(candidates) > 1 {
+		fmt.Printf("Found %d feeds at %s:\n", len(candidates), cmd.args[1])
+		for _, candidate := range candidates {
+			if candidate.Title != "" {
+				fmt.Printf("* %s (%s)\n", candidate.URL, candidate.Title)
+			} else {
+				fmt.Printf("* %s\n", candidate.URL)
+			}
+		}
+		return fmt.Errorf("several feeds found, try: %v %q <feed_url>", cmd.name, name)
+	}
+	url := candidates[0].URL
+	if url != cmd.args[1] {
+		fmt.Printf("Found feed %s\n", url)
+	}
 	feed, err := s.dbQr.CreateFeed(context.Background(), database.CreateFeedParams{
 		ID:        uuid.New(),
 		CreatedAt: time.Now().UTC(),
@@ -46,9 +77,24 @@ func handlerAddFeed(s *state, cmd command, user database.User) error {
 	return nil
 }
 
-// handlerListFeeds gets all the feeds from the feed table
+// handlerListFeeds gets all the feeds from the feed table,
+// or only the disabled and failing ones with the --broken flag
 func handlerListFeeds(s *state, cmd command) error {
-	feeds, err := s.dbQr.GetFeeds(context.Background())
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	broken := fs.Bool("broken", false, "list only the disabled and failing feeds")
+	args, err := parseFlags(fs, cmd.args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return fmt.Errorf("usage: %v [--broken]", cmd.name)
+	}
+	var feeds []database.Feed
+	if *broken {
+		feeds, err = s.dbQr.GetBrokenFeeds(context.Background())
+	} else {
+		feeds, err = s.dbQr.GetFeeds(context.Background())
+	}
 	if err != nil {
 		return fmt.Errorf("couldn't get feeds: %w", err)
 	}
@@ -76,4 +122,105 @@ func printFeed(feed database.Feed, user database.User) {
 	fmt.Printf("* URL:           %s\n", feed.Url)
 	fmt.Printf("* User:          %s\n", user.Name)
 	fmt.Printf("* LastFetchedAt: %v\n", feed.LastFetchedAt.Time)
+	if feed.CustomIntervalSeconds > 0 {
+		fmt.Printf("* Interval:      %s (custom)\n", time.Duration(feed.CustomIntervalSeconds)*time.Second)
+	} else if feed.AdaptiveIntervalSeconds > 0 {
+		fmt.Printf("* Interval:      %s (adapted to publishing frequency)\n",
+			time.Duration(feed.AdaptiveIntervalSeconds)*time.Second)
+	}
+	if feed.ErrorCount > 0 || feed.DisabledAt.Valid {
+		fmt.Printf("* Errors:        %d in a row since %v\n", feed.ErrorCount, feed.FailingSince.Time)
+		fmt.Printf("* LastStatus:    %d\n", feed.LastStatusCode)
+		fmt.Printf("* LastError:     %s\n", feed.LastError)
+	}
+	if feed.FullContent {
+		fmt.Printf("* FullContent:   on\n")
+	}
+	if feed.DisabledAt.Valid {
+		fmt.Printf("* DisabledAt:    %v\n", feed.DisabledAt.Time)
+	}
+}
+
+// handlerRevive clears the failures of a feed, enables it again if it was disabled,
+// and fetches it immediately
+func handlerRevive(s *state, cmd command) error {
+	if len(cmd.args) != 1 {
+		return fmt.Errorf("usage: %v <url>", cmd.name)
+	}
+	ctx := context.Background()
+	feed, err := s.dbQr.ReviveFeed(ctx, database.ReviveFeedParams{
+		Url:       cmd.args[0],
+		UpdatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't revive feed: %w", err)
+	}
+	log.Printf("Feed %s revived, fetching it...", feed.Name)
+	stats := &aggStats{startedAt: time.Now()}
+	err = scrapeFeed(ctx, s, feed, defaultAggOptions, stats)
+	if markErr := s.dbQr.MarkFeedFetched(ctx, database.MarkFeedFetchedParams{
+		ID:        feed.ID,
+		UpdatedAt: time.Now().UTC(),
+	}); markErr != nil {
+		err = errors.Join(err, fmt.Errorf("couldn't mark feed as fetched: %w", markErr))
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't scrape feed: %w", err)
+	}
+	stats.print()
+	return nil
+}
+
+// maxCustomInterval caps the collection interval set for a feed, which is stored in seconds
+const maxCustomInterval = 365 * 24 * time.Hour
+
+// handlerSetInterval overrides the collection interval of agg for a specific feed,
+// a zero duration removes the override
+func handlerSetInterval(s *state, cmd command) error {
+	if len(cmd.args) != 2 {
+		return fmt.Errorf("usage: %v <url> <duration>", cmd.name)
+	}
+	interval, err := time.ParseDuration(cmd.args[1])
+	if err != nil {
+		return fmt.Errorf("invalid duration: %w", err)
+	}
+	if interval < 0 || (interval > 0 && interval < time.Second) || interval > maxCustomInterval {
+		return errors.New("invalid duration: must be 0 or between 1s and 8760h (a year)")
+	}
+	feed, err := s.dbQr.SetFeedCustomInterval(context.Background(), database.SetFeedCustomIntervalParams{
+		Url:                   cmd.args[0],
+		UpdatedAt:             time.Now().UTC(),
+		CustomIntervalSeconds: int32(interval / time.Second),
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't set feed interval: %w", err)
+	}
+	if interval == 0 {
+		fmt.Printf("Feed %s now uses the agg interval.\n", feed.Name)
+		return nil
+	}
+	fmt.Printf("Feed %s is now collected every %s.\n", feed.Name, interval)
+	return nil
+}
+
+// handlerFullContent turns the full-content mode of a feed on or off, in which the aggregator
+// extracts the article of the web page of each new post
+func handlerFullContent(s *state, cmd command) error {
+	if len(cmd.args) != 2 || (cmd.args[1] != "on" && cmd.args[1] != "off") {
+		return fmt.Errorf("usage: %v <url> <on|off>", cmd.name)
+	}
+	feed, err := s.dbQr.SetFeedFullContent(context.Background(), database.SetFeedFullContentParams{
+		Url:         cmd.args[0],
+		UpdatedAt:   time.Now().UTC(),
+		FullContent: cmd.args[1] == "on",
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't set feed full-content mode: %w", err)
+	}
+	if feed.FullContent {
+		fmt.Printf("The full content of the new posts of feed %s will be collected.\n", feed.Name)
+		return nil
+	}
+	fmt.Printf("Only the descriptions of the posts of feed %s will be collected.\n", feed.Name)
+	return nil
 }
@@ -12,13 +12,28 @@ func handlerHelp(_ *state, _ command) error {
 	fmt.Println("login <name>              - Set an existing user as the current user")
 	fmt.Println("users                     - List all registered users")
 	fmt.Println("reset                     - Delete all users from the database (dev only!)")
-	fmt.Println("addfeed <name> <url>      - Add a new feed and follow it as current user")
-	fmt.Println("feeds                     - List all available feeds")
+	fmt.Println("addfeed <name> <url>      - Add a new feed and follow it as current user, the URL may be a website's")
+	fmt.Println("feeds [--broken]          - List all available feeds, or only the disabled and failing ones")
+	fmt.Println("revive <url>              - Enable a disabled feed again, and fetch it immediately")
+	fmt.Println("setinterval <url> <d>     - Collect a feed every duration instead of the agg one (0 to reset)")
+	fmt.Println("fullcontent <url> on|off  - Extract the full article of the new posts of a feed from their page")
 	fmt.Println("follow <url>              - Follow an existing feed by URL")
 	fmt.Println("unfollow <url>            - Unfollow a feed by URL")
 	fmt.Println("following                 - List feeds followed by current user")
 	fmt.Println("browse [limit]            - Browse posts from followed feeds (default limit is 2)")
+	fmt.Println("    [--full]              - Show the full content of posts when it was collected")
+	fmt.Println("    [--json]              - Print posts as JSON, with their image and enclosures")
+	fmt.Println("download <post>           - Download the enclosures of a post, given by ID or URL, to download_dir")
 	fmt.Println("agg [duration]            - Collect feeds once or every duration (e.g., 10s, 1m)")
+	fmt.Println("    [--workers N]         - Number of feeds fetched in parallel (default is 1)")
+	fmt.Println("    [--batch N]           - Number of feeds collected at each tick (default is workers)")
+	fmt.Println("    [--per-host N]        - Maximum parallel fetches to the same host (default is 2)")
+	fmt.Println("    [--drain duration]    - Time given to in-flight fetches on shutdown (default is 30s)")
+	fmt.Println("    [--disable-after d]   - Time after which a failing feed is disabled (default is 168h)")
+	fmt.Println("    [--min-interval d]    - Lower bound of intervals adapted to feeds (default is duration)")
+	fmt.Println("    [--max-interval d]    - Upper bound of intervals adapted to feeds (default is 24h)")
+	fmt.Println("    [--max-size bytes]    - Maximum size of a feed document (default is 10485760)")
+	fmt.Println("    [--download-enclosures] - Download the enclosures of new posts, such as podcast episodes")
 	fmt.Println("help                      - Show this help message")
 	fmt.Println("=====================================")
 	return nil
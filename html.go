@@ -0,0 +1,182 @@
+package main
+
+import (
+	"html"
+	"slices"
+	"strings"
+)
+
+// htmlTokenType is the kind of an HTML token
+type htmlTokenType int
+
+const (
+	// htmlText is a run of text, whose entities are decoded
+	htmlText htmlTokenType = iota
+	// htmlStartTag is an opening tag, such as <p>
+	htmlStartTag
+	// htmlEndTag is a closing tag, such as </p>
+	htmlEndTag
+	// htmlSelfClosingTag is a tag closed by itself, such as <br/>
+	htmlSelfClosingTag
+)
+
+// htmlRawTextElements are the elements whose content is text up to their closing tag, whatever it holds
+var htmlRawTextElements = []string{"script", "style", "textarea", "title"}
+
+// htmlVoidElements are the elements which never have content, nor closing tags
+var htmlVoidElements = []string{
+	"area", "base", "br", "col", "embed", "hr", "img", "input", "link", "meta", "source", "track", "wbr",
+}
+
+// htmlAttr is an attribute of an HTML tag
+type htmlAttr struct {
+	Key string
+	Val string
+}
+
+// htmlToken is a token of an HTML document
+type htmlToken struct {
+	// Type is the kind of the token
+	Type htmlTokenType
+	// Name is the lowercase name of a tag
+	Name string
+	// Attrs are the attributes of a start tag, with lowercase keys and decoded values
+	Attrs []htmlAttr
+	// Data is the text of a text token
+	Data string
+}
+
+// attr returns the value of the attribute of the tag, empty when it has none
+func (t htmlToken) attr(key string) string {
+	for _, a := range t.Attrs {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// tokenizeHTML splits an HTML document or fragment into tokens, leniently, as malformed markup is common in feeds.
+// Comments, doctypes and processing instructions are dropped, and the content of raw text elements
+// is kept undecoded in a single text token.
+func tokenizeHTML(s string) []htmlToken {
+	var tokens []htmlToken
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '<')
+		if i < 0 {
+			tokens = append(tokens, htmlToken{Type: htmlText, Data: html.UnescapeString(s)})
+			break
+		}
+		if i > 0 {
+			tokens = append(tokens, htmlToken{Type: htmlText, Data: html.UnescapeString(s[:i])})
+			s = s[i:]
+		}
+		switch {
+		case strings.HasPrefix(s, "<!--"):
+			s = skipPast(s[4:], "-->")
+		case strings.HasPrefix(s, "<!"), strings.HasPrefix(s, "<?"):
+			s = skipPast(s[2:], ">")
+		case strings.HasPrefix(s, "</") && len(s) > 2 && isASCIILetter(s[2]):
+			name, rest := readTagName(s[2:])
+			tokens = append(tokens, htmlToken{Type: htmlEndTag, Name: name})
+			s = skipPast(rest, ">")
+		case len(s) > 1 && isASCIILetter(s[1]):
+			var tok htmlToken
+			tok, s = readStartTag(s[1:])
+			tokens = append(tokens, tok)
+			if tok.Type == htmlStartTag && slices.Contains(htmlRawTextElements, tok.Name) {
+				end := indexFold(s, "</"+tok.Name)
+				if end < 0 {
+					end = len(s)
+				}
+				if end > 0 {
+					tokens = append(tokens, htmlToken{Type: htmlText, Data: s[:end]})
+				}
+				s = s[end:]
+			}
+		default:
+			tokens = append(tokens, htmlToken{Type: htmlText, Data: "<"})
+			s = s[1:]
+		}
+	}
+	return tokens
+}
+
+// readStartTag reads a start tag and its attributes, right after its "<", and returns the rest of the input
+func readStartTag(s string) (htmlToken, string) {
+	tok := htmlToken{Type: htmlStartTag}
+	tok.Name, s = readTagName(s)
+	for {
+		s = strings.TrimLeft(s, " \t\r\n\f")
+		switch {
+		case s == "":
+			return tok, s
+		case s[0] == '>':
+			return tok, s[1:]
+		case strings.HasPrefix(s, "/>"):
+			tok.Type = htmlSelfClosingTag
+			return tok, s[2:]
+		case s[0] == '/':
+			s = s[1:]
+			continue
+		}
+		end := strings.IndexAny(s, " \t\r\n\f=/>")
+		if end < 0 {
+			end = len(s)
+		}
+		attr := htmlAttr{Key: strings.ToLower(s[:end])}
+		s = strings.TrimLeft(s[end:], " \t\r\n\f")
+		if strings.HasPrefix(s, "=") {
+			s = strings.TrimLeft(s[1:], " \t\r\n\f")
+			var val string
+			if s != "" && (s[0] == '"' || s[0] == '\'') {
+				end = strings.IndexByte(s[1:], s[0])
+				if end < 0 {
+					val, s = s[1:], ""
+				} else {
+					val, s = s[1:end+1], s[end+2:]
+				}
+			} else {
+				end = strings.IndexAny(s, " \t\r\n\f>")
+				if end < 0 {
+					end = len(s)
+				}
+				val, s = s[:end], s[end:]
+			}
+			attr.Val = html.UnescapeString(val)
+		}
+		tok.Attrs = append(tok.Attrs, attr)
+	}
+}
+
+// readTagName reads the lowercase name of a tag, and returns the rest of the input
+func readTagName(s string) (string, string) {
+	end := strings.IndexAny(s, " \t\r\n\f/>")
+	if end < 0 {
+		end = len(s)
+	}
+	return strings.ToLower(s[:end]), s[end:]
+}
+
+// skipPast returns the input after the first occurrence of the delimiter, or nothing when it's missing
+func skipPast(s, delim string) string {
+	if i := strings.Index(s, delim); i >= 0 {
+		return s[i+len(delim):]
+	}
+	return ""
+}
+
+// indexFold returns the index of the first occurrence of the ASCII substring, ignoring case, or -1
+func indexFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+// isASCIILetter reports whether the byte is an ASCII letter
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -9,10 +9,28 @@ import (
 // configFilePath represents the JSON configuration file path
 const configFilePath = ".config/go-feedo/config.json"
 
+// defaultDownloadDir represents the directory where enclosures are downloaded, relative to the home directory,
+// when the configuration doesn't set one
+const defaultDownloadDir = "Downloads/go-feedo"
+
 // DatabaseConfig holds configuration values for the database
 type DatabaseConfig struct {
 	URL             string `json:"db_url"`
 	CurrentUserName string `json:"current_user_name"`
+	DownloadDir     string `json:"download_dir,omitempty"`
+}
+
+// DownloadDirectory returns the directory where enclosures are downloaded,
+// the configured one, or the default one in the home directory
+func (db DatabaseConfig) DownloadDirectory() (string, error) {
+	if db.DownloadDir != "" {
+		return db.DownloadDir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, defaultDownloadDir), nil
 }
 
 // SetUser configures the current user name for the database
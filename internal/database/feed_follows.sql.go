@@ -132,3 +132,23 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 	}
 	return items, nil
 }
+
+const moveFeedFollows = `-- name: MoveFeedFollows :exec
+
+UPDATE feed_follows
+SET updated_at = $1,
+    feed_id = $2
+WHERE feed_id = $3
+AND user_id NOT IN (SELECT user_id FROM feed_follows WHERE feed_id = $2)
+`
+
+type MoveFeedFollowsParams struct {
+	UpdatedAt time.Time
+	NewFeedID uuid.UUID
+	OldFeedID uuid.UUID
+}
+
+func (q *Queries) MoveFeedFollows(ctx context.Context, arg MoveFeedFollowsParams) error {
+	_, err := q.db.ExecContext(ctx, moveFeedFollows, arg.UpdatedAt, arg.NewFeedID, arg.OldFeedID)
+	return err
+}
@@ -7,15 +7,86 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const claimFeedsToFetch = `-- name: ClaimFeedsToFetch :many
+UPDATE feeds
+SET updated_at = $1,
+    lease_expires_at = $2
+WHERE id IN (
+    SELECT id FROM feeds
+    WHERE (lease_expires_at IS NULL OR lease_expires_at < $1)
+    AND (next_fetch_at IS NULL OR next_fetch_at <= $1)
+    AND disabled_at IS NULL
+    ORDER BY last_fetched_at NULLS FIRST
+    LIMIT $3
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, lease_expires_at, error_count, last_error, last_status_code, next_fetch_at, failing_since, disabled_at, adaptive_interval_seconds, custom_interval_seconds, full_content, poll_min_delay_seconds, poll_skip_hours, poll_skip_days, gone_count
+`
+
+type ClaimFeedsToFetchParams struct {
+	UpdatedAt      time.Time
+	LeaseExpiresAt sql.NullTime
+	Limit          int32
+}
+
+func (q *Queries) ClaimFeedsToFetch(ctx context.Context, arg ClaimFeedsToFetchParams) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, claimFeedsToFetch, arg.UpdatedAt, arg.LeaseExpiresAt, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.LeaseExpiresAt,
+			&i.ErrorCount,
+			&i.LastError,
+			&i.LastStatusCode,
+			&i.NextFetchAt,
+			&i.FailingSince,
+			&i.DisabledAt,
+			&i.AdaptiveIntervalSeconds,
+			&i.CustomIntervalSeconds,
+			&i.FullContent,
+			&i.PollMinDelaySeconds,
+			pq.Array(&i.PollSkipHours),
+			pq.Array(&i.PollSkipDays),
+			&i.GoneCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createFeed = `-- name: CreateFeed :one
 INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
 VALUES ($1, $2, $3, $4, $5, $6)
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, lease_expires_at, error_count, last_error, last_status_code, next_fetch_at, failing_since, disabled_at, adaptive_interval_seconds, custom_interval_seconds, full_content, poll_min_delay_seconds, poll_skip_hours, poll_skip_days, gone_count
 `
 
 type CreateFeedParams struct {
@@ -45,12 +116,91 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LeaseExpiresAt,
+		&i.ErrorCount,
+		&i.LastError,
+		&i.LastStatusCode,
+		&i.NextFetchAt,
+		&i.FailingSince,
+		&i.DisabledAt,
+		&i.AdaptiveIntervalSeconds,
+		&i.CustomIntervalSeconds,
+		&i.FullContent,
+		&i.PollMinDelaySeconds,
+		pq.Array(&i.PollSkipHours),
+		pq.Array(&i.PollSkipDays),
+		&i.GoneCount,
 	)
 	return i, err
 }
 
+const deleteFeed = `-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1
+`
+
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeed, id)
+	return err
+}
+
+const getBrokenFeeds = `-- name: GetBrokenFeeds :many
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, lease_expires_at, error_count, last_error, last_status_code, next_fetch_at, failing_since, disabled_at, adaptive_interval_seconds, custom_interval_seconds, full_content, poll_min_delay_seconds, poll_skip_hours, poll_skip_days, gone_count FROM feeds
+WHERE disabled_at IS NOT NULL OR error_count > 0
+ORDER BY disabled_at NULLS LAST, error_count DESC
+`
+
+func (q *Queries) GetBrokenFeeds(ctx context.Context) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getBrokenFeeds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.LeaseExpiresAt,
+			&i.ErrorCount,
+			&i.LastError,
+			&i.LastStatusCode,
+			&i.NextFetchAt,
+			&i.FailingSince,
+			&i.DisabledAt,
+			&i.AdaptiveIntervalSeconds,
+			&i.CustomIntervalSeconds,
+			&i.FullContent,
+			&i.PollMinDelaySeconds,
+			pq.Array(&i.PollSkipHours),
+			pq.Array(&i.PollSkipDays),
+			&i.GoneCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getFeedByURL = `-- name: GetFeedByURL :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, lease_expires_at, error_count, last_error, last_status_code, next_fetch_at, failing_since, disabled_at, adaptive_interval_seconds, custom_interval_seconds, full_content, poll_min_delay_seconds, poll_skip_hours, poll_skip_days, gone_count FROM feeds
 WHERE url = $1
 `
 
@@ -65,12 +215,28 @@ func (q *Queries) GetFeedByURL(ctx context.Context, url string) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LeaseExpiresAt,
+		&i.ErrorCount,
+		&i.LastError,
+		&i.LastStatusCode,
+		&i.NextFetchAt,
+		&i.FailingSince,
+		&i.DisabledAt,
+		&i.AdaptiveIntervalSeconds,
+		&i.CustomIntervalSeconds,
+		&i.FullContent,
+		&i.PollMinDelaySeconds,
+		pq.Array(&i.PollSkipHours),
+		pq.Array(&i.PollSkipDays),
+		&i.GoneCount,
 	)
 	return i, err
 }
 
 const getFeeds = `-- name: GetFeeds :many
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, lease_expires_at, error_count, last_error, last_status_code, next_fetch_at, failing_since, disabled_at, adaptive_interval_seconds, custom_interval_seconds, full_content, poll_min_delay_seconds, poll_skip_hours, poll_skip_days, gone_count FROM feeds
 `
 
 func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
@@ -90,6 +256,22 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 			&i.Url,
 			&i.UserID,
 			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.LeaseExpiresAt,
+			&i.ErrorCount,
+			&i.LastError,
+			&i.LastStatusCode,
+			&i.NextFetchAt,
+			&i.FailingSince,
+			&i.DisabledAt,
+			&i.AdaptiveIntervalSeconds,
+			&i.CustomIntervalSeconds,
+			&i.FullContent,
+			&i.PollMinDelaySeconds,
+			pq.Array(&i.PollSkipHours),
+			pq.Array(&i.PollSkipDays),
+			&i.GoneCount,
 		); err != nil {
 			return nil, err
 		}
@@ -104,14 +286,154 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 	return items, nil
 }
 
-const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
-ORDER BY last_fetched_at NULLS FIRST
+const getNextFetchAt = `-- name: GetNextFetchAt :one
+SELECT next_fetch_at FROM feeds
+WHERE disabled_at IS NULL
+AND (lease_expires_at IS NULL OR lease_expires_at < $1::timestamp)
+ORDER BY next_fetch_at NULLS FIRST
 LIMIT 1
 `
 
-func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, getNextFeedToFetch)
+func (q *Queries) GetNextFetchAt(ctx context.Context, now time.Time) (sql.NullTime, error) {
+	row := q.db.QueryRowContext(ctx, getNextFetchAt, now)
+	var next_fetch_at sql.NullTime
+	err := row.Scan(&next_fetch_at)
+	return next_fetch_at, err
+}
+
+const markFeedFetched = `-- name: MarkFeedFetched :exec
+UPDATE feeds
+SET updated_at = $2,
+    last_fetched_at = $2,
+    lease_expires_at = NULL
+WHERE id = $1
+`
+
+type MarkFeedFetchedParams struct {
+	ID        uuid.UUID
+	UpdatedAt time.Time
+}
+
+func (q *Queries) MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams) error {
+	_, err := q.db.ExecContext(ctx, markFeedFetched, arg.ID, arg.UpdatedAt)
+	return err
+}
+
+const recordFeedFailure = `-- name: RecordFeedFailure :exec
+UPDATE feeds
+SET updated_at = $2,
+    error_count = error_count + 1,
+    last_error = $3,
+    last_status_code = $4,
+    next_fetch_at = $5,
+    failing_since = COALESCE(failing_since, $2),
+    disabled_at = $6,
+    gone_count = $7
+WHERE id = $1
+`
+
+type RecordFeedFailureParams struct {
+	ID             uuid.UUID
+	UpdatedAt      time.Time
+	LastError      string
+	LastStatusCode int32
+	NextFetchAt    sql.NullTime
+	DisabledAt     sql.NullTime
+	GoneCount      int32
+}
+
+func (q *Queries) RecordFeedFailure(ctx context.Context, arg RecordFeedFailureParams) error {
+	_, err := q.db.ExecContext(ctx, recordFeedFailure,
+		arg.ID,
+		arg.UpdatedAt,
+		arg.LastError,
+		arg.LastStatusCode,
+		arg.NextFetchAt,
+		arg.DisabledAt,
+		arg.GoneCount,
+	)
+	return err
+}
+
+const recordFeedSuccess = `-- name: RecordFeedSuccess :exec
+UPDATE feeds
+SET updated_at = $2,
+    error_count = 0,
+    last_error = '',
+    last_status_code = $3,
+    next_fetch_at = $4,
+    failing_since = NULL,
+    gone_count = 0,
+    adaptive_interval_seconds = $5,
+    poll_min_delay_seconds = $6,
+    poll_skip_hours = $7,
+    poll_skip_days = $8
+WHERE id = $1
+`
+
+type RecordFeedSuccessParams struct {
+	ID                      uuid.UUID
+	UpdatedAt               time.Time
+	LastStatusCode          int32
+	NextFetchAt             sql.NullTime
+	AdaptiveIntervalSeconds int32
+	PollMinDelaySeconds     int32
+	PollSkipHours           []int32
+	PollSkipDays            []string
+}
+
+func (q *Queries) RecordFeedSuccess(ctx context.Context, arg RecordFeedSuccessParams) error {
+	_, err := q.db.ExecContext(ctx, recordFeedSuccess,
+		arg.ID,
+		arg.UpdatedAt,
+		arg.LastStatusCode,
+		arg.NextFetchAt,
+		arg.AdaptiveIntervalSeconds,
+		arg.PollMinDelaySeconds,
+		pq.Array(arg.PollSkipHours),
+		pq.Array(arg.PollSkipDays),
+	)
+	return err
+}
+
+const releaseFeedLease = `-- name: ReleaseFeedLease :exec
+UPDATE feeds
+SET updated_at = $2,
+    lease_expires_at = NULL
+WHERE id = $1
+`
+
+type ReleaseFeedLeaseParams struct {
+	ID        uuid.UUID
+	UpdatedAt time.Time
+}
+
+func (q *Queries) ReleaseFeedLease(ctx context.Context, arg ReleaseFeedLeaseParams) error {
+	_, err := q.db.ExecContext(ctx, releaseFeedLease, arg.ID, arg.UpdatedAt)
+	return err
+}
+
+const reviveFeed = `-- name: ReviveFeed :one
+UPDATE feeds
+SET updated_at = $2,
+    error_count = 0,
+    last_error = '',
+    last_status_code = 0,
+    next_fetch_at = NULL,
+    failing_since = NULL,
+    disabled_at = NULL,
+    gone_count = 0
+WHERE url = $1
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, lease_expires_at, error_count, last_error, last_status_code, next_fetch_at, failing_since, disabled_at, adaptive_interval_seconds, custom_interval_seconds, full_content, poll_min_delay_seconds, poll_skip_hours, poll_skip_days, gone_count
+`
+
+type ReviveFeedParams struct {
+	Url       string
+	UpdatedAt time.Time
+}
+
+func (q *Queries) ReviveFeed(ctx context.Context, arg ReviveFeedParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, reviveFeed, arg.Url, arg.UpdatedAt)
 	var i Feed
 	err := row.Scan(
 		&i.ID,
@@ -121,23 +443,156 @@ func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LeaseExpiresAt,
+		&i.ErrorCount,
+		&i.LastError,
+		&i.LastStatusCode,
+		&i.NextFetchAt,
+		&i.FailingSince,
+		&i.DisabledAt,
+		&i.AdaptiveIntervalSeconds,
+		&i.CustomIntervalSeconds,
+		&i.FullContent,
+		&i.PollMinDelaySeconds,
+		pq.Array(&i.PollSkipHours),
+		pq.Array(&i.PollSkipDays),
+		&i.GoneCount,
 	)
 	return i, err
 }
 
-const markFeedFetched = `-- name: MarkFeedFetched :exec
+const setFeedCustomInterval = `-- name: SetFeedCustomInterval :one
 UPDATE feeds
 SET updated_at = $2,
-    last_fetched_at = $2
+    custom_interval_seconds = $3,
+    next_fetch_at = NULL
+WHERE url = $1
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, lease_expires_at, error_count, last_error, last_status_code, next_fetch_at, failing_since, disabled_at, adaptive_interval_seconds, custom_interval_seconds, full_content, poll_min_delay_seconds, poll_skip_hours, poll_skip_days, gone_count
+`
+
+type SetFeedCustomIntervalParams struct {
+	Url                   string
+	UpdatedAt             time.Time
+	CustomIntervalSeconds int32
+}
+
+func (q *Queries) SetFeedCustomInterval(ctx context.Context, arg SetFeedCustomIntervalParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, setFeedCustomInterval, arg.Url, arg.UpdatedAt, arg.CustomIntervalSeconds)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LeaseExpiresAt,
+		&i.ErrorCount,
+		&i.LastError,
+		&i.LastStatusCode,
+		&i.NextFetchAt,
+		&i.FailingSince,
+		&i.DisabledAt,
+		&i.AdaptiveIntervalSeconds,
+		&i.CustomIntervalSeconds,
+		&i.FullContent,
+		&i.PollMinDelaySeconds,
+		pq.Array(&i.PollSkipHours),
+		pq.Array(&i.PollSkipDays),
+		&i.GoneCount,
+	)
+	return i, err
+}
+
+const setFeedFullContent = `-- name: SetFeedFullContent :one
+UPDATE feeds
+SET updated_at = $2,
+    full_content = $3
+WHERE url = $1
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, lease_expires_at, error_count, last_error, last_status_code, next_fetch_at, failing_since, disabled_at, adaptive_interval_seconds, custom_interval_seconds, full_content, poll_min_delay_seconds, poll_skip_hours, poll_skip_days, gone_count
+`
+
+type SetFeedFullContentParams struct {
+	Url         string
+	UpdatedAt   time.Time
+	FullContent bool
+}
+
+func (q *Queries) SetFeedFullContent(ctx context.Context, arg SetFeedFullContentParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, setFeedFullContent, arg.Url, arg.UpdatedAt, arg.FullContent)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LeaseExpiresAt,
+		&i.ErrorCount,
+		&i.LastError,
+		&i.LastStatusCode,
+		&i.NextFetchAt,
+		&i.FailingSince,
+		&i.DisabledAt,
+		&i.AdaptiveIntervalSeconds,
+		&i.CustomIntervalSeconds,
+		&i.FullContent,
+		&i.PollMinDelaySeconds,
+		pq.Array(&i.PollSkipHours),
+		pq.Array(&i.PollSkipDays),
+		&i.GoneCount,
+	)
+	return i, err
+}
+
+const updateFeedURL = `-- name: UpdateFeedURL :exec
+UPDATE feeds
+SET updated_at = $2,
+    url = $3
 WHERE id = $1
 `
 
-type MarkFeedFetchedParams struct {
+type UpdateFeedURLParams struct {
 	ID        uuid.UUID
 	UpdatedAt time.Time
+	Url       string
 }
 
-func (q *Queries) MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams) error {
-	_, err := q.db.ExecContext(ctx, markFeedFetched, arg.ID, arg.UpdatedAt)
+func (q *Queries) UpdateFeedURL(ctx context.Context, arg UpdateFeedURLParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedURL, arg.ID, arg.UpdatedAt, arg.Url)
+	return err
+}
+
+const updateFeedValidators = `-- name: UpdateFeedValidators :exec
+UPDATE feeds
+SET updated_at = $2,
+    etag = $3,
+    last_modified = $4
+WHERE id = $1
+`
+
+type UpdateFeedValidatorsParams struct {
+	ID           uuid.UUID
+	UpdatedAt    time.Time
+	Etag         string
+	LastModified string
+}
+
+func (q *Queries) UpdateFeedValidators(ctx context.Context, arg UpdateFeedValidatorsParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedValidators,
+		arg.ID,
+		arg.UpdatedAt,
+		arg.Etag,
+		arg.LastModified,
+	)
 	return err
 }
@@ -11,14 +11,49 @@ import (
 	"github.com/google/uuid"
 )
 
+type Enclosure struct {
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	PostID              uuid.UUID
+	Url                 string
+	MimeType            string
+	Length              int64
+	DurationSeconds     int32
+	Episode             int32
+	Season              int32
+	DownloadedAt        sql.NullTime
+	FilePath            string
+	Sha256              string
+	DownloadRequestedAt sql.NullTime
+	LeaseExpiresAt      sql.NullTime
+	DownloadAttempts    int32
+}
+
 type Feed struct {
-	ID            uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Name          string
-	Url           string
-	UserID        uuid.UUID
-	LastFetchedAt sql.NullTime
+	ID                      uuid.UUID
+	CreatedAt               time.Time
+	UpdatedAt               time.Time
+	Name                    string
+	Url                     string
+	UserID                  uuid.UUID
+	LastFetchedAt           sql.NullTime
+	Etag                    string
+	LastModified            string
+	LeaseExpiresAt          sql.NullTime
+	ErrorCount              int32
+	LastError               string
+	LastStatusCode          int32
+	NextFetchAt             sql.NullTime
+	FailingSince            sql.NullTime
+	DisabledAt              sql.NullTime
+	AdaptiveIntervalSeconds int32
+	CustomIntervalSeconds   int32
+	FullContent             bool
+	PollMinDelaySeconds     int32
+	PollSkipHours           []int32
+	PollSkipDays            []string
+	GoneCount               int32
 }
 
 type FeedFollow struct {
@@ -30,14 +65,27 @@ type FeedFollow struct {
 }
 
 type Post struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description string
-	PublishedAt sql.NullTime
-	FeedID      uuid.UUID
+	ID                    uuid.UUID
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+	Title                 string
+	Url                   string
+	Description           string
+	PublishedAt           sql.NullTime
+	FeedID                uuid.UUID
+	Guid                  string
+	ContentHash           string
+	Revision              int32
+	SanitizedDescription  string
+	Content               string
+	Author                string
+	Categories            []string
+	CommentsUrl           string
+	ImageUrl              string
+	ContentRequestedAt    sql.NullTime
+	ContentLeaseExpiresAt sql.NullTime
+	ContentAttempts       int32
+	TextNormalized        bool
 }
 
 type User struct {
@@ -11,23 +11,125 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const adoptPostGUID = `-- name: AdoptPostGUID :exec
+
+UPDATE posts
+SET guid = $1
+WHERE id = (
+    SELECT id FROM posts
+    WHERE feed_id = $2 AND url = $3 AND guid = url
+    AND NOT EXISTS (SELECT 1 FROM posts WHERE feed_id = $2 AND guid = $1)
+    LIMIT 1
+)
+`
+
+type AdoptPostGUIDParams struct {
+	Guid   string
+	FeedID uuid.UUID
+	Url    string
+}
+
+func (q *Queries) AdoptPostGUID(ctx context.Context, arg AdoptPostGUIDParams) error {
+	_, err := q.db.ExecContext(ctx, adoptPostGUID, arg.Guid, arg.FeedID, arg.Url)
+	return err
+}
+
+const claimPostToFetchContent = `-- name: ClaimPostToFetchContent :one
+
+UPDATE posts
+SET content_lease_expires_at = $1,
+    content_attempts = content_attempts + 1
+WHERE id = (
+    SELECT id FROM posts
+    WHERE content_requested_at IS NOT NULL
+    AND content_attempts < $2
+    AND (content_lease_expires_at IS NULL OR content_lease_expires_at < $3)
+    ORDER BY content_requested_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, guid, content_hash, revision, sanitized_description, content, author, categories, comments_url, image_url, content_requested_at, content_lease_expires_at, content_attempts, text_normalized
+`
+
+type ClaimPostToFetchContentParams struct {
+	LeaseExpiresAt sql.NullTime
+	MaxAttempts    int32
+	Now            time.Time
+}
+
+func (q *Queries) ClaimPostToFetchContent(ctx context.Context, arg ClaimPostToFetchContentParams) (Post, error) {
+	row := q.db.QueryRowContext(ctx, claimPostToFetchContent, arg.LeaseExpiresAt, arg.MaxAttempts, arg.Now)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Guid,
+		&i.ContentHash,
+		&i.Revision,
+		&i.SanitizedDescription,
+		&i.Content,
+		&i.Author,
+		pq.Array(&i.Categories),
+		&i.CommentsUrl,
+		&i.ImageUrl,
+		&i.ContentRequestedAt,
+		&i.ContentLeaseExpiresAt,
+		&i.ContentAttempts,
+		&i.TextNormalized,
+	)
+	return i, err
+}
+
 const createPost = `-- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, guid, content_hash,
+    sanitized_description, content, author, categories, comments_url, image_url, content_requested_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET updated_at = EXCLUDED.updated_at,
+    title = EXCLUDED.title,
+    url = EXCLUDED.url,
+    description = EXCLUDED.description,
+    content_hash = EXCLUDED.content_hash,
+    sanitized_description = EXCLUDED.sanitized_description,
+    content = EXCLUDED.content,
+    author = EXCLUDED.author,
+    categories = EXCLUDED.categories,
+    comments_url = EXCLUDED.comments_url,
+    image_url = EXCLUDED.image_url,
+    content_requested_at = EXCLUDED.content_requested_at,
+    content_attempts = 0,
+    revision = posts.revision + 1
+WHERE posts.content_hash <> EXCLUDED.content_hash
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, guid, content_hash, revision, sanitized_description, content, author, categories, comments_url, image_url, content_requested_at, content_lease_expires_at, content_attempts, text_normalized
 `
 
 type CreatePostParams struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description string
-	PublishedAt sql.NullTime
-	FeedID      uuid.UUID
+	ID                   uuid.UUID
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+	Title                string
+	Url                  string
+	Description          string
+	PublishedAt          sql.NullTime
+	FeedID               uuid.UUID
+	Guid                 string
+	ContentHash          string
+	SanitizedDescription string
+	Content              string
+	Author               string
+	Categories           []string
+	CommentsUrl          string
+	ImageUrl             string
+	ContentRequestedAt   sql.NullTime
 }
 
 func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
@@ -40,6 +142,15 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		arg.Description,
 		arg.PublishedAt,
 		arg.FeedID,
+		arg.Guid,
+		arg.ContentHash,
+		arg.SanitizedDescription,
+		arg.Content,
+		arg.Author,
+		pq.Array(arg.Categories),
+		arg.CommentsUrl,
+		arg.ImageUrl,
+		arg.ContentRequestedAt,
 	)
 	var i Post
 	err := row.Scan(
@@ -51,13 +162,56 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.Guid,
+		&i.ContentHash,
+		&i.Revision,
+		&i.SanitizedDescription,
+		&i.Content,
+		&i.Author,
+		pq.Array(&i.Categories),
+		&i.CommentsUrl,
+		&i.ImageUrl,
+		&i.ContentRequestedAt,
+		&i.ContentLeaseExpiresAt,
+		&i.ContentAttempts,
+		&i.TextNormalized,
 	)
 	return i, err
 }
 
+const getFeedPublishingStats = `-- name: GetFeedPublishingStats :one
+
+SELECT
+    COUNT(*)::int AS post_count,
+    COALESCE(EXTRACT(EPOCH FROM MAX(published_at) - MIN(published_at)), 0)::bigint AS span_seconds
+FROM (
+    SELECT DISTINCT published_at FROM posts
+    WHERE feed_id = $1 AND published_at IS NOT NULL
+    ORDER BY published_at DESC
+    LIMIT $2
+) AS recent_posts
+`
+
+type GetFeedPublishingStatsParams struct {
+	FeedID uuid.UUID
+	Limit  int32
+}
+
+type GetFeedPublishingStatsRow struct {
+	PostCount   int32
+	SpanSeconds int64
+}
+
+func (q *Queries) GetFeedPublishingStats(ctx context.Context, arg GetFeedPublishingStatsParams) (GetFeedPublishingStatsRow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedPublishingStats, arg.FeedID, arg.Limit)
+	var i GetFeedPublishingStatsRow
+	err := row.Scan(&i.PostCount, &i.SpanSeconds)
+	return i, err
+}
+
 const getPostsForUser = `-- name: GetPostsForUser :many
 
-SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, feeds.name AS feed_name FROM posts
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.guid, posts.content_hash, posts.revision, posts.sanitized_description, posts.content, posts.author, posts.categories, posts.comments_url, posts.image_url, posts.content_requested_at, posts.content_lease_expires_at, posts.content_attempts, posts.text_normalized, feeds.name AS feed_name FROM posts
 JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
 JOIN feeds ON posts.feed_id = feeds.id
 WHERE feed_follows.user_id = $1
@@ -71,15 +225,28 @@ type GetPostsForUserParams struct {
 }
 
 type GetPostsForUserRow struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description string
-	PublishedAt sql.NullTime
-	FeedID      uuid.UUID
-	FeedName    string
+	ID                    uuid.UUID
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+	Title                 string
+	Url                   string
+	Description           string
+	PublishedAt           sql.NullTime
+	FeedID                uuid.UUID
+	Guid                  string
+	ContentHash           string
+	Revision              int32
+	SanitizedDescription  string
+	Content               string
+	Author                string
+	Categories            []string
+	CommentsUrl           string
+	ImageUrl              string
+	ContentRequestedAt    sql.NullTime
+	ContentLeaseExpiresAt sql.NullTime
+	ContentAttempts       int32
+	TextNormalized        bool
+	FeedName              string
 }
 
 func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
@@ -100,6 +267,19 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.Guid,
+			&i.ContentHash,
+			&i.Revision,
+			&i.SanitizedDescription,
+			&i.Content,
+			&i.Author,
+			pq.Array(&i.Categories),
+			&i.CommentsUrl,
+			&i.ImageUrl,
+			&i.ContentRequestedAt,
+			&i.ContentLeaseExpiresAt,
+			&i.ContentAttempts,
+			&i.TextNormalized,
 			&i.FeedName,
 		); err != nil {
 			return nil, err
@@ -114,3 +294,127 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 	}
 	return items, nil
 }
+
+const getPostsToNormalize = `-- name: GetPostsToNormalize :many
+
+SELECT id, title, description FROM posts
+WHERE NOT text_normalized
+LIMIT $1
+`
+
+type GetPostsToNormalizeRow struct {
+	ID          uuid.UUID
+	Title       string
+	Description string
+}
+
+func (q *Queries) GetPostsToNormalize(ctx context.Context, limit int32) ([]GetPostsToNormalizeRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsToNormalize, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPostsToNormalizeRow
+	for rows.Next() {
+		var i GetPostsToNormalizeRow
+		if err := rows.Scan(&i.ID, &i.Title, &i.Description); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const movePosts = `-- name: MovePosts :exec
+
+UPDATE posts
+SET updated_at = $1,
+    feed_id = $2
+WHERE feed_id = $3
+AND guid NOT IN (SELECT guid FROM posts WHERE feed_id = $2)
+`
+
+type MovePostsParams struct {
+	UpdatedAt time.Time
+	NewFeedID uuid.UUID
+	OldFeedID uuid.UUID
+}
+
+func (q *Queries) MovePosts(ctx context.Context, arg MovePostsParams) error {
+	_, err := q.db.ExecContext(ctx, movePosts, arg.UpdatedAt, arg.NewFeedID, arg.OldFeedID)
+	return err
+}
+
+const normalizePostText = `-- name: NormalizePostText :exec
+
+UPDATE posts
+SET title = $2,
+    description = $3,
+    sanitized_description = $4,
+    content_hash = $5,
+    text_normalized = true
+WHERE id = $1
+`
+
+type NormalizePostTextParams struct {
+	ID                   uuid.UUID
+	Title                string
+	Description          string
+	SanitizedDescription string
+	ContentHash          string
+}
+
+func (q *Queries) NormalizePostText(ctx context.Context, arg NormalizePostTextParams) error {
+	_, err := q.db.ExecContext(ctx, normalizePostText,
+		arg.ID,
+		arg.Title,
+		arg.Description,
+		arg.SanitizedDescription,
+		arg.ContentHash,
+	)
+	return err
+}
+
+const releasePostContentLease = `-- name: ReleasePostContentLease :exec
+
+UPDATE posts
+SET content_lease_expires_at = $2
+WHERE id = $1
+`
+
+type ReleasePostContentLeaseParams struct {
+	ID                    uuid.UUID
+	ContentLeaseExpiresAt sql.NullTime
+}
+
+func (q *Queries) ReleasePostContentLease(ctx context.Context, arg ReleasePostContentLeaseParams) error {
+	_, err := q.db.ExecContext(ctx, releasePostContentLease, arg.ID, arg.ContentLeaseExpiresAt)
+	return err
+}
+
+const updatePostContent = `-- name: UpdatePostContent :exec
+
+UPDATE posts
+SET updated_at = $2,
+    content = $3,
+    content_requested_at = NULL,
+    content_lease_expires_at = NULL
+WHERE id = $1
+`
+
+type UpdatePostContentParams struct {
+	ID        uuid.UUID
+	UpdatedAt time.Time
+	Content   string
+}
+
+func (q *Queries) UpdatePostContent(ctx context.Context, arg UpdatePostContentParams) error {
+	_, err := q.db.ExecContext(ctx, updatePostContent, arg.ID, arg.UpdatedAt, arg.Content)
+	return err
+}
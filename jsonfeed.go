@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// jsonFeedVersionPrefix is the prefix of the version URL every JSON Feed document declares
+const jsonFeedVersionPrefix = "https://jsonfeed.org/version/"
+
+// JSONFeed represents a JSON Feed (version 1.0 or 1.1) parsed from JSON
+type JSONFeed struct {
+	// Version is the URL of the version of the format the feed uses
+	Version string `json:"version"`
+	// Title is the feed's title
+	Title string `json:"title"`
+	// HomePageURL is the URL of the website the feed describes
+	HomePageURL string `json:"home_page_url"`
+	// Description provides details about the feed
+	Description string `json:"description"`
+	// Authors is the list of authors of the feed, the default authors of its items (version 1.1)
+	Authors []JSONFeedAuthor `json:"authors"`
+	// Author is the author of the feed, the default author of its items (version 1.0)
+	Author *JSONFeedAuthor `json:"author"`
+	// Items is the list of feed entries
+	Items []JSONFeedItem `json:"items"`
+}
+
+// JSONFeedItem represents an individual entry in a JSON Feed
+type JSONFeedItem struct {
+	// ID is the unique identifier of the item
+	ID JSONFeedID `json:"id"`
+	// URL is the permalink of the item
+	URL string `json:"url"`
+	// Title of the item
+	Title string `json:"title"`
+	// Summary is a plain text summary of the item
+	Summary string `json:"summary"`
+	// ContentHTML is the HTML content of the item
+	ContentHTML string `json:"content_html"`
+	// ContentText is the plain text content of the item
+	ContentText string `json:"content_text"`
+	// DatePublished is the publication date of the item in RFC 3339 format
+	DatePublished string `json:"date_published"`
+	// DateModified is the last modification date of the item in RFC 3339 format
+	DateModified string `json:"date_modified"`
+	// Authors is the list of authors of the item (version 1.1)
+	Authors []JSONFeedAuthor `json:"authors"`
+	// Author is the author of the item (version 1.0)
+	Author *JSONFeedAuthor `json:"author"`
+	// Tags is the list of tags of the item
+	Tags []string `json:"tags"`
+	// Image is the URL of the main image of the item
+	Image string `json:"image"`
+	// BannerImage is the URL of an image to use as a banner for the item
+	BannerImage string `json:"banner_image"`
+	// Attachments is the list of files attached to the item, such as podcast episodes
+	Attachments []JSONFeedAttachment `json:"attachments"`
+}
+
+// JSONFeedAttachment represents a file attached to a JSON Feed item
+type JSONFeedAttachment struct {
+	// URL is the location of the file
+	URL string `json:"url"`
+	// MIMEType is the type of the file
+	MIMEType string `json:"mime_type"`
+	// SizeInBytes is the size of the file
+	SizeInBytes int64 `json:"size_in_bytes"`
+	// DurationInSeconds is the duration of the audio or video file
+	DurationInSeconds float64 `json:"duration_in_seconds"`
+}
+
+// JSONFeedAuthor represents an author of a JSON Feed or of one of its items
+type JSONFeedAuthor struct {
+	// Name is the name of the author
+	Name string `json:"name"`
+	// URL is the URL of a site owned by the author
+	URL string `json:"url"`
+}
+
+// JSONFeedID is the identifier of a JSON Feed item, which some feeds give as a number
+// although the specification requires a string
+type JSONFeedID string
+
+// UnmarshalJSON decodes the identifier from a string, or from a number kept as written, as readers must do.
+// Any other value leaves the identifier empty rather than failing the whole feed.
+func (id *JSONFeedID) UnmarshalJSON(data []byte) error {
+	var value any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case string:
+		*id = JSONFeedID(v)
+	case json.Number:
+		*id = JSONFeedID(v.String())
+	default:
+		// an item without a usable identifier is identified by its URL
+		*id = ""
+	}
+	return nil
+}
+
+// isJSONFeed reports whether the document is a JSON Feed, either from its declared content type,
+// or because it's a JSON object, which can't be any of the XML feed formats
+func isJSONFeed(br *bufio.Reader, contentType string) bool {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType == "application/feed+json" {
+		return true
+	}
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
+			return false
+		}
+		if !unicode.IsSpace(rune(c)) {
+			_ = br.UnreadByte()
+			return c == '{'
+		}
+	}
+}
+
+// parseJSONFeed decodes a JSON Feed document while reading it, and normalizes it into an RSSFeed
+func parseJSONFeed(r io.Reader) (*RSSFeed, error) {
+	var jsonFeed JSONFeed
+	if err := json.NewDecoder(r).Decode(&jsonFeed); err != nil {
+		return nil, fmt.Errorf("couldn't decode JSON feed: %w", err)
+	}
+	if !strings.HasPrefix(jsonFeed.Version, jsonFeedVersionPrefix) {
+		return nil, fmt.Errorf("unsupported JSON document: version %q isn't a JSON Feed version", jsonFeed.Version)
+	}
+	return jsonFeed.toRSSFeed(), nil
+}
+
+// toRSSFeed normalizes the JSON Feed into the RSSFeed model persisted by the aggregator
+func (f *JSONFeed) toRSSFeed() *RSSFeed {
+	var rssFeed RSSFeed
+	rssFeed.Channel.Title = f.Title
+	rssFeed.Channel.Link = f.HomePageURL
+	rssFeed.Channel.Description = f.Description
+	rssFeed.Channel.Item = make([]RSSItem, 0, len(f.Items))
+	for _, item := range f.Items {
+		description := item.ContentHTML
+		if description == "" {
+			description = item.ContentText
+		}
+		if description == "" {
+			description = item.Summary
+		}
+		pubDate := item.DatePublished
+		if pubDate == "" {
+			pubDate = item.DateModified
+		}
+		authors := jsonFeedAuthors(item.Authors, item.Author)
+		if authors == "" {
+			authors = jsonFeedAuthors(f.Authors, f.Author)
+		}
+		var duration string
+		enclosures := make([]RSSEnclosure, 0, len(item.Attachments))
+		for _, attachment := range item.Attachments {
+			var length string
+			if attachment.SizeInBytes > 0 {
+				length = strconv.FormatInt(attachment.SizeInBytes, 10)
+			}
+			if duration == "" && attachment.DurationInSeconds > 0 {
+				duration = strconv.Itoa(int(attachment.DurationInSeconds))
+			}
+			enclosures = append(enclosures, RSSEnclosure{URL: attachment.URL, Type: attachment.MIMEType, Length: length})
+		}
+		var media MediaGroup
+		for _, image := range []string{item.Image, item.BannerImage} {
+			if image != "" {
+				media.Thumbnails = append(media.Thumbnails, MediaThumbnail{URL: image})
+			}
+		}
+		rssFeed.Channel.Item = append(rssFeed.Channel.Item, RSSItem{
+			GUID:        string(item.ID),
+			Title:       item.Title,
+			Link:        item.URL,
+			Description: description,
+			PubDate:     pubDate,
+			Content:     item.ContentHTML,
+			Creator:     authors,
+			Categories:  item.Tags,
+			Enclosures:  enclosures,
+			Duration:    duration,
+			MediaGroup:  media,
+		})
+	}
+	return &rssFeed
+}
+
+// jsonFeedAuthors joins the names of the authors of version 1.1, or the name of the author of version 1.0
+func jsonFeedAuthors(authors []JSONFeedAuthor, author *JSONFeedAuthor) string {
+	if len(authors) == 0 && author != nil {
+		authors = []JSONFeedAuthor{*author}
+	}
+	names := make([]string, 0, len(authors))
+	for _, a := range authors {
+		if a.Name != "" {
+			names = append(names, a.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONFeedIDUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"string", `"urn:uuid:1"`, "urn:uuid:1"},
+		{"empty string", `""`, ""},
+		{"integer", `42`, "42"},
+		{"large integer", `12345678901234567890`, "12345678901234567890"},
+		{"negative integer", `-1`, "-1"},
+		{"decimal", `1.50`, "1.50"},
+		{"exponent", `1e3`, "1e3"},
+		{"null", `null`, ""},
+		{"boolean", `true`, ""},
+		{"object", `{"id": 1}`, ""},
+		{"array", `[1]`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var item JSONFeedItem
+			if err := json.Unmarshal([]byte(`{"id": `+tt.value+`}`), &item); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			if got := string(item.ID); got != tt.want {
+				t.Errorf("ID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFeedJSONFeedItems(t *testing.T) {
+	tests := []struct {
+		name  string
+		items string
+		want  []RSSItem
+	}{
+		{
+			name: "full item",
+			items: `[{
+				"id": "https://example.com/1",
+				"url": "https://example.com/posts/1",
+				"title": "First post",
+				"summary": "A summary",
+				"content_html": "<p>Hello</p>",
+				"content_text": "Hello",
+				"date_published": "2020-01-02T03:04:05Z",
+				"date_modified": "2020-02-02T03:04:05Z",
+				"authors": [{"name": "Jane"}, {"name": "John"}],
+				"tags": ["news", "go"]
+			}]`,
+			want: []RSSItem{{
+				GUID:        "https://example.com/1",
+				Title:       "First post",
+				Link:        "https://example.com/posts/1",
+				Description: "<p>Hello</p>",
+				PubDate:     "2020-01-02T03:04:05Z",
+				Content:     "<p>Hello</p>",
+				Creator:     "Jane, John",
+				Categories:  []string{"news", "go"},
+			}},
+		},
+		{
+			name:  "numeric ids",
+			items: `[{"id": 1, "url": "https://example.com/1"}, {"id": 2.5, "url": "https://example.com/2"}]`,
+			want: []RSSItem{
+				{GUID: "1", Link: "https://example.com/1", Creator: "Feed Author"},
+				{GUID: "2.5", Link: "https://example.com/2", Creator: "Feed Author"},
+			},
+		},
+		{
+			name:  "invalid id falls back to the URL",
+			items: `[{"id": null, "url": "https://example.com/1"}, {"id": {}, "url": "https://example.com/2"}]`,
+			want: []RSSItem{
+				{Link: "https://example.com/1", Creator: "Feed Author"},
+				{Link: "https://example.com/2", Creator: "Feed Author"},
+			},
+		},
+		{
+			name:  "text content without HTML content",
+			items: `[{"id": "1", "summary": "A summary", "content_text": "Hello &amp; bye"}]`,
+			want:  []RSSItem{{GUID: "1", Description: "Hello & bye", Creator: "Feed Author"}},
+		},
+		{
+			name:  "summary without content",
+			items: `[{"id": "1", "summary": "A summary"}]`,
+			want:  []RSSItem{{GUID: "1", Description: "A summary", Creator: "Feed Author"}},
+		},
+		{
+			name:  "modification date without publication date",
+			items: `[{"id": "1", "date_modified": "2020-02-02T03:04:05Z"}]`,
+			want:  []RSSItem{{GUID: "1", PubDate: "2020-02-02T03:04:05Z", Creator: "Feed Author"}},
+		},
+		{
+			name:  "version 1.0 author",
+			items: `[{"id": "1", "author": {"name": "Jane"}}]`,
+			want:  []RSSItem{{GUID: "1", Creator: "Jane"}},
+		},
+		{
+			name:  "feed authors inherited",
+			items: `[{"id": "1"}]`,
+			want:  []RSSItem{{GUID: "1", Creator: "Feed Author"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed := mustParseFeed(t, `{
+				"version": "https://jsonfeed.org/version/1.1",
+				"title": "Example",
+				"home_page_url": "https://example.com/",
+				"authors": [{"name": "Feed Author"}],
+				"items": `+tt.items+`
+			}`, "application/feed+json")
+			items := feed.Channel.Item
+			if len(items) != len(tt.want) {
+				t.Fatalf("got %d items, want %d", len(items), len(tt.want))
+			}
+			for i, want := range tt.want {
+				checkItem(t, items[i], want)
+			}
+		})
+	}
+}
+
+func TestParseFeedJSONFeedAttachments(t *testing.T) {
+	feed := mustParseFeed(t, `{
+		"version": "https://jsonfeed.org/version/1",
+		"title": "Podcast",
+		"items": [{
+			"id": "1",
+			"image": "https://example.com/cover.jpg",
+			"attachments": [
+				{
+					"url": "https://example.com/1.mp3", "mime_type": "audio/mpeg",
+					"size_in_bytes": 1234, "duration_in_seconds": 61.5
+				},
+				{"url": "https://example.com/1.m4a", "mime_type": "audio/mp4"}
+			]
+		}]
+	}`, "application/feed+json")
+	item := feed.Channel.Item[0]
+	want := []RSSEnclosure{
+		{URL: "https://example.com/1.mp3", Type: "audio/mpeg", Length: "1234"},
+		{URL: "https://example.com/1.m4a", Type: "audio/mp4"},
+	}
+	if len(item.Enclosures) != len(want) {
+		t.Fatalf("got %d enclosures, want %d", len(item.Enclosures), len(want))
+	}
+	for i := range want {
+		if item.Enclosures[i] != want[i] {
+			t.Errorf("enclosure %d = %+v, want %+v", i, item.Enclosures[i], want[i])
+		}
+	}
+	if item.Duration != "61" {
+		t.Errorf("duration = %q, want %q", item.Duration, "61")
+	}
+	if got := item.image(); got != "https://example.com/cover.jpg" {
+		t.Errorf("image() = %q, want %q", got, "https://example.com/cover.jpg")
+	}
+}
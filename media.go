@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// MediaGroup holds the Media RSS elements describing the media of an item, found either directly
+// in the item, or grouped in a media:group element
+type MediaGroup struct {
+	// Thumbnails are the images representing the media
+	Thumbnails []MediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	// Contents are the media objects of the item
+	Contents []MediaContent `xml:"http://search.yahoo.com/mrss/ content"`
+}
+
+// MediaThumbnail represents a media:thumbnail element
+type MediaThumbnail struct {
+	// URL is the location of the image
+	URL string `xml:"url,attr"`
+}
+
+// MediaContent represents a media:content element
+type MediaContent struct {
+	// URL is the location of the media object
+	URL string `xml:"url,attr"`
+	// Type is the MIME type of the media object
+	Type string `xml:"type,attr"`
+	// Medium is the kind of the media object, such as "image" or "video"
+	Medium string `xml:"medium,attr"`
+	// Thumbnails are the images representing the media object
+	Thumbnails []MediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+}
+
+// ITunesImage represents an itunes:image element
+type ITunesImage struct {
+	// Href is the location of the image
+	Href string `xml:"href,attr"`
+}
+
+// image returns the URL of the first image found in the Media RSS elements, thumbnails first
+func (g MediaGroup) image() string {
+	for _, thumbnail := range g.Thumbnails {
+		if thumbnail.URL != "" {
+			return thumbnail.URL
+		}
+	}
+	for _, content := range g.Contents {
+		for _, thumbnail := range content.Thumbnails {
+			if thumbnail.URL != "" {
+				return thumbnail.URL
+			}
+		}
+	}
+	for _, content := range g.Contents {
+		if content.URL != "" && (content.Medium == "image" || strings.HasPrefix(content.Type, "image/")) {
+			return content.URL
+		}
+	}
+	return ""
+}
+
+// image returns the URL of an image representing the item: a Media RSS thumbnail or image,
+// the iTunes image of the episode, or the first image of its content or description, in that order.
+// Relative URLs are resolved against the link of the item.
+func (item RSSItem) image() string {
+	image := item.MediaGroup.image()
+	for _, group := range item.MediaGroups {
+		if image != "" {
+			break
+		}
+		image = group.image()
+	}
+	if image == "" {
+		image = item.ITunesImage.Href
+	}
+	if image == "" {
+		image = firstHTMLImage(item.Content)
+	}
+	if image == "" {
+		image = firstHTMLImage(item.Description)
+	}
+	if image = strings.TrimSpace(image); image == "" {
+		return ""
+	}
+	base, err := url.Parse(item.Link)
+	if err != nil || !base.IsAbs() {
+		return image
+	}
+	return resolveURL(base, image)
+}
+
+// firstHTMLImage returns the source of the first image of an HTML fragment which isn't a tracking pixel
+func firstHTMLImage(s string) string {
+	if !strings.Contains(s, "<") {
+		return ""
+	}
+	for _, tok := range tokenizeHTML(s) {
+		if tok.Type == htmlText || tok.Name != "img" || isTrackingPixel(tok) {
+			continue
+		}
+		if src := strings.TrimSpace(tok.attr("src")); src != "" && !strings.HasPrefix(src, "data:") {
+			return src
+		}
+	}
+	return ""
+}
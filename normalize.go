@@ -0,0 +1,101 @@
+package main
+
+import (
+	"html"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// normalize cleans up the text of the channel and of its items, whatever the source format of the feed was
+func (f *RSSFeed) normalize() {
+	f.Channel.Title = normalizeText(f.Channel.Title)
+	f.Channel.Link = normalizeText(f.Channel.Link)
+	f.Channel.Description = normalizeHTML(f.Channel.Description)
+	for i := range f.Channel.Item {
+		item := &f.Channel.Item[i]
+		item.GUID = normalizeText(item.GUID)
+		item.Title = normalizeText(item.Title)
+		if item.Title == "" {
+			item.Title = normalizeText(item.ITunesTitle)
+		}
+		if item.Title == "" {
+			item.Title = normalizeText(item.MediaTitle)
+		}
+		item.Link = normalizeText(item.Link)
+		item.Description = normalizeHTML(item.Description)
+		if item.Description == "" {
+			item.Description = normalizeHTML(item.MediaDescription)
+		}
+		item.PubDate = normalizeText(item.PubDate)
+		item.Content = normalizeHTML(item.Content)
+		item.Author = normalizeText(item.Author)
+		item.ITunesAuthor = normalizeText(item.ITunesAuthor)
+		item.Creator = normalizeText(item.Creator)
+		item.Comments = normalizeText(item.Comments)
+		item.Categories = normalizeCategories(item.Categories)
+		item.Duration = normalizeText(item.Duration)
+		item.Episode = normalizeText(item.Episode)
+		item.Season = normalizeText(item.Season)
+		enclosures := item.Enclosures[:0]
+		for _, enclosure := range item.Enclosures {
+			enclosure.URL = normalizeText(enclosure.URL)
+			enclosure.Type = normalizeText(enclosure.Type)
+			enclosure.Length = normalizeText(enclosure.Length)
+			if enclosure.URL != "" {
+				enclosures = append(enclosures, enclosure)
+			}
+		}
+		item.Enclosures = enclosures
+	}
+}
+
+// normalizeCategories cleans up the categories of an item, dropping the empty and duplicate ones.
+// The result is never nil, which pq.Array would store as NULL.
+func normalizeCategories(categories []string) []string {
+	normalized := make([]string, 0, len(categories))
+	for _, category := range categories {
+		if category = normalizeText(category); category != "" && !slices.Contains(normalized, category) {
+			normalized = append(normalized, category)
+		}
+	}
+	return normalized
+}
+
+// normalizeText cleans up a plain text field: the entities left by feeds escaping their text twice
+// are decoded, and control characters are stripped, and whitespace runs are collapsed into single spaces
+func normalizeText(s string) string {
+	s = html.UnescapeString(unwrapCDATA(s))
+	return strings.Join(strings.FieldsFunc(stripControl(s), unicode.IsSpace), " ")
+}
+
+// normalizeHTML cleans up a field which may hold markup, whose entities are only decoded when it
+// holds none, because decoding them would turn escaped text into tags
+func normalizeHTML(s string) string {
+	s = strings.TrimSpace(stripControl(unwrapCDATA(s)))
+	if !strings.Contains(s, "<") {
+		return normalizeText(s)
+	}
+	return s
+}
+
+// unwrapCDATA removes the CDATA section markers left in the text of feeds escaping their CDATA sections
+func unwrapCDATA(s string) string {
+	trimmed := strings.TrimSpace(s)
+	for _, markers := range [][2]string{{"<![CDATA[", "]]>"}, {"&lt;![CDATA[", "]]&gt;"}} {
+		if strings.HasPrefix(trimmed, markers[0]) && strings.HasSuffix(trimmed, markers[1]) {
+			return trimmed[len(markers[0]) : len(trimmed)-len(markers[1])]
+		}
+	}
+	return s
+}
+
+// stripControl removes the control characters, except for the whitespace ones
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && !unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alnah/go-feedo/internal/database"
+)
+
+// RSSPollingHints are the channel elements a publisher uses to tell aggregators how often to fetch its feed
+type RSSPollingHints struct {
+	// TTL is the number of minutes the feed can be cached before being fetched again
+	TTL string `xml:"ttl"`
+	// SkipHours are the hours (GMT, from 0 to 23) during which the feed shouldn't be fetched
+	SkipHours []string `xml:"skipHours>hour"`
+	// SkipDays are the days of the week during which the feed shouldn't be fetched
+	SkipDays []string `xml:"skipDays>day"`
+	// UpdatePeriod is the period over which the feed is updated: hourly, daily, weekly, monthly or yearly
+	UpdatePeriod string `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+	// UpdateFrequency is the number of updates during the update period, 1 when omitted
+	UpdateFrequency string `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+}
+
+// updatePeriods maps the update periods of the syndication module to their duration
+var updatePeriods = map[string]time.Duration{
+	"hourly":  time.Hour,
+	"daily":   24 * time.Hour,
+	"weekly":  7 * 24 * time.Hour,
+	"monthly": 30 * 24 * time.Hour,
+	"yearly":  365 * 24 * time.Hour,
+}
+
+// minDelay returns the minimum delay between two fetches declared by the ttl and the syndication module,
+// zero when the feed declares neither of them
+func (h RSSPollingHints) minDelay() time.Duration {
+	var delay time.Duration
+	if ttl, err := strconv.Atoi(strings.TrimSpace(h.TTL)); err == nil && ttl > 0 {
+		delay = time.Duration(ttl) * time.Minute
+	}
+	if period, ok := updatePeriods[strings.ToLower(strings.TrimSpace(h.UpdatePeriod))]; ok {
+		frequency, err := strconv.Atoi(strings.TrimSpace(h.UpdateFrequency))
+		if err != nil || frequency < 1 {
+			frequency = 1
+		}
+		delay = max(delay, period/time.Duration(frequency))
+	}
+	return delay
+}
+
+// schedule normalizes the polling hints, ignoring the invalid hours and days
+func (h RSSPollingHints) schedule() pollingSchedule {
+	sched := pollingSchedule{
+		minDelay:  h.minDelay(),
+		skipHours: make([]int32, 0, len(h.SkipHours)),
+		skipDays:  make([]string, 0, len(h.SkipDays)),
+	}
+	for _, hour := range h.SkipHours {
+		// some feeds number the hours from 1 to 24
+		n, err := strconv.Atoi(strings.TrimSpace(hour))
+		if err == nil && n >= 0 && n <= 24 && !slices.Contains(sched.skipHours, int32(n%24)) {
+			sched.skipHours = append(sched.skipHours, int32(n%24))
+		}
+	}
+	for _, day := range h.SkipDays {
+		for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+			if strings.EqualFold(strings.TrimSpace(day), weekday.String()) &&
+				!slices.Contains(sched.skipDays, weekday.String()) {
+				sched.skipDays = append(sched.skipDays, weekday.String())
+			}
+		}
+	}
+	return sched
+}
+
+// pollingSchedule is the normalized form of the polling hints of a feed. It's stored on the feed,
+// so that the hints still apply to the fetches answered with 304 Not Modified, which don't repeat them.
+type pollingSchedule struct {
+	// minDelay is the minimum delay between two fetches
+	minDelay time.Duration
+	// skipHours are the hours (GMT, from 0 to 23) during which the feed shouldn't be fetched
+	skipHours []int32
+	// skipDays are the days of the week during which the feed shouldn't be fetched, as named by time.Weekday
+	skipDays []string
+}
+
+// feedPollingSchedule returns the polling schedule stored on the feed by its last successful fetch
+func feedPollingSchedule(feed database.Feed) pollingSchedule {
+	return pollingSchedule{
+		minDelay:  time.Duration(feed.PollMinDelaySeconds) * time.Second,
+		skipHours: feed.PollSkipHours,
+		skipDays:  feed.PollSkipDays,
+	}
+}
+
+// skipped reports whether the feed asks not to be fetched at the given time
+func (sched pollingSchedule) skipped(t time.Time) bool {
+	t = t.UTC()
+	return slices.Contains(sched.skipHours, int32(t.Hour())) || slices.Contains(sched.skipDays, t.Weekday().String())
+}
+
+// nextFetchAt computes when the feed should be fetched again after a fetch at now,
+// given the interval between two fetches of the feed
+func (sched pollingSchedule) nextFetchAt(now time.Time, interval time.Duration) time.Time {
+	next := now.Add(max(sched.minDelay, interval))
+	// skipped hours and days are moved to the start of the next allowed hour, within a week
+	for range 7 * 24 {
+		if !sched.skipped(next) {
+			break
+		}
+		next = next.Truncate(time.Hour).Add(time.Hour)
+	}
+	return next
+}
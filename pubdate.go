@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// pubDateLayouts lists the publication date formats found in real-world feeds, tried in order,
+// each one with an example of a date it parses
+var pubDateLayouts = []string{
+	time.RFC1123Z,                        // Mon, 02 Jan 2006 15:04:05 -0700
+	"Mon, 2 Jan 2006 15:04:05 -0700",     // Mon, 2 Jan 2006 15:04:05 -0700
+	"Mon, 2 Jan 2006 15:04 -0700",        // Mon, 2 Jan 2006 15:04 -0700
+	"Mon, 2 January 2006 15:04:05 -0700", // Monday, 2 January 2006 15:04:05 -0700
+	"2 Jan 2006 15:04:05 -0700",          // 2 Jan 2006 15:04:05 -0700
+	"2 Jan 2006 15:04 -0700",             // 2 Jan 2006 15:04 -0700
+	"Mon, 2 Jan 06 15:04:05 -0700",       // Mon, 2 Jan 06 15:04:05 -0700 (RFC 822)
+	"Mon, 2 Jan 2006 15:04:05",           // Mon, 2 Jan 2006 15:04:05 (no zone, assumed UTC)
+	"Mon, 2 Jan 2006",                    // Mon, 2 Jan 2006
+	"2 Jan 2006",                         // 2 Jan 2006
+	time.RFC3339Nano,                     // 2006-01-02T15:04:05.999999999-07:00 (Atom, JSON Feed, dc:date)
+	"2006-01-02T15:04Z07:00",             // 2006-01-02T15:04-07:00 (dc:date without seconds)
+	"2006-01-02T15:04:05",                // 2006-01-02T15:04:05 (no zone, assumed UTC)
+	"2006-01-02T15:04:05-0700",           // 2006-01-02T15:04:05-0700
+	"2006-01-02 15:04:05 -0700",          // 2006-01-02 15:04:05 -0700
+	"2006-01-02 15:04:05Z07:00",          // 2006-01-02 15:04:05+07:00
+	"2006-01-02 15:04:05",                // 2006-01-02 15:04:05 (no zone, assumed UTC)
+	"2006-01-02",                         // 2006-01-02 (W3C date)
+	"2006-01",                            // 2006-01 (W3C year and month)
+	"Mon Jan 2 15:04:05 -0700 2006",      // Mon Jan 2 15:04:05 MST 2006 (date command)
+	"Mon Jan 2 15:04:05 2006",            // Mon Jan 2 15:04:05 2006 (ANSI C)
+}
+
+// zoneOffsets maps the named time zones allowed by RFC 822, and the ones commonly used by European
+// and Asia-Pacific feeds, to their numeric offsets, because time.Parse gives a zero offset to the names
+// it doesn't know from the local zone
+var zoneOffsets = map[string]string{
+	"UT":   "+0000",
+	"UTC":  "+0000",
+	"GMT":  "+0000",
+	"Z":    "+0000",
+	"EST":  "-0500",
+	"EDT":  "-0400",
+	"CST":  "-0600",
+	"CDT":  "-0500",
+	"MST":  "-0700",
+	"MDT":  "-0600",
+	"PST":  "-0800",
+	"PDT":  "-0700",
+	"WET":  "+0000",
+	"WEST": "+0100",
+	"BST":  "+0100",
+	"CET":  "+0100",
+	"CEST": "+0200",
+	"MET":  "+0100",
+	"MEST": "+0200",
+	"EET":  "+0200",
+	"EEST": "+0300",
+	"MSK":  "+0300",
+	"JST":  "+0900",
+	"KST":  "+0900",
+	"HKT":  "+0800",
+	"AWST": "+0800",
+	"ACST": "+0930",
+	"ACDT": "+1030",
+	"AEST": "+1000",
+	"AEDT": "+1100",
+	"NZST": "+1200",
+	"NZDT": "+1300",
+}
+
+// parsePubDate parses a publication date in any of the common feed date formats,
+// it reports false when the date doesn't match any known format
+func parsePubDate(value string) (time.Time, bool) {
+	value = normalizePubDate(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range pubDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// normalizePubDate trims and collapses whitespace, drops the day of the week,
+// and replaces the named time zones with their numeric offsets
+func normalizePubDate(value string) string {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return ""
+	}
+	// the day of the week is often wrong or written in full, and carries no information
+	if strings.HasSuffix(fields[0], ",") {
+		fields[0] = "Mon,"
+	}
+	for i := 1; i < len(fields); i++ {
+		if offset, ok := zoneOffsets[strings.ToUpper(fields[i])]; ok {
+			fields[i] = offset
+		}
+	}
+	return strings.Join(fields, " ")
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePubDate(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"RFC 1123 numeric zone", "Wed, 02 Oct 2002 13:00:00 +0200", "2002-10-02T11:00:00Z"},
+		{"RFC 1123 GMT", "Wed, 02 Oct 2002 13:00:00 GMT", "2002-10-02T13:00:00Z"},
+		{"RFC 1123 lowercase zone", "Wed, 02 Oct 2002 13:00:00 gmt", "2002-10-02T13:00:00Z"},
+		{"US zone", "Wed, 02 Oct 2002 08:00:00 EST", "2002-10-02T13:00:00Z"},
+		{"US daylight zone", "Wed, 02 Oct 2002 06:00:00 PDT", "2002-10-02T13:00:00Z"},
+		{"European zone", "Wed, 02 Oct 2002 13:00:00 CET", "2002-10-02T12:00:00Z"},
+		{"European summer zone", "Wed, 02 Oct 2002 15:00:00 CEST", "2002-10-02T13:00:00Z"},
+		{"British summer zone", "Wed, 02 Oct 2002 14:00:00 BST", "2002-10-02T13:00:00Z"},
+		{"Eastern European zone", "Wed, 02 Oct 2002 15:00:00 EET", "2002-10-02T13:00:00Z"},
+		{"Australian zone", "Wed, 02 Oct 2002 23:00:00 AEST", "2002-10-02T13:00:00Z"},
+		{"single-digit day", "Wed, 2 Oct 2002 13:00:00 +0000", "2002-10-02T13:00:00Z"},
+		{"missing seconds", "Wed, 2 Oct 2002 13:00 +0000", "2002-10-02T13:00:00Z"},
+		{"full weekday and month", "Wednesday, 2 October 2002 13:00:00 +0000", "2002-10-02T13:00:00Z"},
+		{"wrong weekday", "Fri, 02 Oct 2002 13:00:00 +0000", "2002-10-02T13:00:00Z"},
+		{"no weekday", "2 Oct 2002 13:00:00 +0000", "2002-10-02T13:00:00Z"},
+		{"no weekday missing seconds", "2 Oct 2002 13:00 +0000", "2002-10-02T13:00:00Z"},
+		{"two-digit year", "Wed, 02 Oct 02 13:00:00 +0000", "2002-10-02T13:00:00Z"},
+		{"no zone", "Wed, 02 Oct 2002 13:00:00", "2002-10-02T13:00:00Z"},
+		{"date only with weekday", "Wed, 02 Oct 2002", "2002-10-02T00:00:00Z"},
+		{"date only", "2 Oct 2002", "2002-10-02T00:00:00Z"},
+		{"extra whitespace", "  Wed,  02 Oct 2002\n 13:00:00   GMT ", "2002-10-02T13:00:00Z"},
+		{"RFC 3339", "2002-10-02T15:00:00+02:00", "2002-10-02T13:00:00Z"},
+		{"RFC 3339 UTC", "2002-10-02T13:00:00Z", "2002-10-02T13:00:00Z"},
+		{"RFC 3339 fractional seconds", "2002-10-02T13:00:00.123Z", "2002-10-02T13:00:00.123Z"},
+		{"ISO 8601 missing seconds", "2002-10-02T15:00+02:00", "2002-10-02T13:00:00Z"},
+		{"ISO 8601 no zone", "2002-10-02T13:00:00", "2002-10-02T13:00:00Z"},
+		{"ISO 8601 compact offset", "2002-10-02T15:00:00+0200", "2002-10-02T13:00:00Z"},
+		{"SQL-like numeric zone", "2002-10-02 15:00:00 +0200", "2002-10-02T13:00:00Z"},
+		{"SQL-like offset", "2002-10-02 15:00:00+02:00", "2002-10-02T13:00:00Z"},
+		{"SQL-like no zone", "2002-10-02 13:00:00", "2002-10-02T13:00:00Z"},
+		{"W3C date", "2002-10-02", "2002-10-02T00:00:00Z"},
+		{"W3C year and month", "2002-10", "2002-10-01T00:00:00Z"},
+		{"date command", "Wed Oct 2 15:00:00 CEST 2002", "2002-10-02T13:00:00Z"},
+		{"ANSI C", "Wed Oct 2 13:00:00 2002", "2002-10-02T13:00:00Z"},
+		{"empty", "", ""},
+		{"whitespace", "   ", ""},
+		{"garbage", "yesterday", ""},
+		{"unknown zone", "Wed, 02 Oct 2002 13:00:00 XYZ", ""},
+		{"invalid day", "Wed, 32 Oct 2002 13:00:00 +0000", ""},
+		{"invalid month", "2002-13-02T13:00:00Z", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parsePubDate(tt.value)
+			if tt.want == "" {
+				if ok {
+					t.Fatalf("parsePubDate(%q) = %v, want failure", tt.value, got)
+				}
+				return
+			}
+			want, err := time.Parse(time.RFC3339Nano, tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ok {
+				t.Fatalf("parsePubDate(%q) failed, want %v", tt.value, want)
+			}
+			if !got.Equal(want) || got.Location() != time.UTC {
+				t.Errorf("parsePubDate(%q) = %v, want %v", tt.value, got, want)
+			}
+		})
+	}
+}
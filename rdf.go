@@ -0,0 +1,66 @@
+package main
+
+// RDFFeed represents an RSS 1.0 feed parsed from XML, where the items are
+// siblings of the channel inside the rdf:RDF root element
+type RDFFeed struct {
+	// Channel contains metadata of the RSS 1.0 feed
+	Channel struct {
+		// Title is the feed's title
+		Title string `xml:"title"`
+		// Link is the URL to the feed
+		Link string `xml:"link"`
+		// Description provides details about the feed
+		Description string `xml:"description"`
+		// RSSPollingHints tell how often the feed should be fetched
+		RSSPollingHints
+	} `xml:"channel"`
+	// Item is the list of feed entries
+	Item []RDFItem `xml:"item"`
+}
+
+// RDFItem represents an individual entry in an RSS 1.0 feed
+type RDFItem struct {
+	// About is the URI identifying the item
+	About string `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+	// Title of the feed item
+	Title string `xml:"title"`
+	// Link to the full content of the item
+	Link string `xml:"link"`
+	// Description or summary of the item
+	Description string `xml:"description"`
+	// Date is the Dublin Core publication date of the item
+	Date string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	// Content is the full HTML content of the item, from content:encoded
+	Content string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	// Creator is the Dublin Core name of the author of the item
+	Creator string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	// Subject is the list of Dublin Core topics of the item
+	Subject []string `xml:"http://purl.org/dc/elements/1.1/ subject"`
+}
+
+// toRSSFeed normalizes the RSS 1.0 feed into the RSSFeed model persisted by the aggregator
+func (f *RDFFeed) toRSSFeed() *RSSFeed {
+	var rssFeed RSSFeed
+	rssFeed.Channel.Title = f.Channel.Title
+	rssFeed.Channel.Link = f.Channel.Link
+	rssFeed.Channel.Description = f.Channel.Description
+	rssFeed.Channel.RSSPollingHints = f.Channel.RSSPollingHints
+	rssFeed.Channel.Item = make([]RSSItem, 0, len(f.Item))
+	for _, item := range f.Item {
+		link := item.Link
+		if link == "" {
+			link = item.About
+		}
+		rssFeed.Channel.Item = append(rssFeed.Channel.Item, RSSItem{
+			GUID:        item.About,
+			Title:       item.Title,
+			Link:        link,
+			Description: item.Description,
+			PubDate:     item.Date,
+			Content:     item.Content,
+			Creator:     item.Creator,
+			Categories:  item.Subject,
+		})
+	}
+	return &rssFeed
+}
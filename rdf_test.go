@@ -0,0 +1,121 @@
+package main
+
+import "testing"
+
+func TestParseFeedRDFItems(t *testing.T) {
+	tests := []struct {
+		name  string
+		items string
+		want  []RSSItem
+	}{
+		{
+			name: "full item",
+			items: `<item rdf:about="https://example.com/1">
+				<title>First</title>
+				<link>https://example.com/posts/1</link>
+				<description>First &amp;amp; foremost</description>
+				<dc:date>2002-10-02T15:00:00+02:00</dc:date>
+				<dc:creator>Jane</dc:creator>
+				<dc:subject>news</dc:subject>
+				<dc:subject>rdf</dc:subject>
+				<content:encoded><![CDATA[<p>First post</p>]]></content:encoded>
+			</item>`,
+			want: []RSSItem{{
+				GUID:        "https://example.com/1",
+				Title:       "First",
+				Link:        "https://example.com/posts/1",
+				Description: "First & foremost",
+				PubDate:     "2002-10-02T15:00:00+02:00",
+				Content:     "<p>First post</p>",
+				Creator:     "Jane",
+				Categories:  []string{"news", "rdf"},
+			}},
+		},
+		{
+			name: "sibling items",
+			items: `<item rdf:about="https://example.com/1"><title>First</title></item>
+				<item rdf:about="https://example.com/2"><title>Second</title></item>
+				<item rdf:about="https://example.com/3"><title>Third</title></item>`,
+			want: []RSSItem{
+				{GUID: "https://example.com/1", Title: "First", Link: "https://example.com/1"},
+				{GUID: "https://example.com/2", Title: "Second", Link: "https://example.com/2"},
+				{GUID: "https://example.com/3", Title: "Third", Link: "https://example.com/3"},
+			},
+		},
+		{
+			name:  "link without about",
+			items: `<item><title>First</title><link>https://example.com/1</link></item>`,
+			want:  []RSSItem{{Title: "First", Link: "https://example.com/1"}},
+		},
+		{
+			name:  "dc:date without zone",
+			items: `<item rdf:about="https://example.com/1"><dc:date>2002-10-02</dc:date></item>`,
+			want:  []RSSItem{{GUID: "https://example.com/1", Link: "https://example.com/1", PubDate: "2002-10-02"}},
+		},
+		{
+			name:  "no items",
+			items: ``,
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed := mustParseFeed(t, rdfDocument(tt.items), "application/rdf+xml")
+			items := feed.Channel.Item
+			if len(items) != len(tt.want) {
+				t.Fatalf("got %d items, want %d", len(items), len(tt.want))
+			}
+			for i, want := range tt.want {
+				checkItem(t, items[i], want)
+				if _, ok := parsePubDate(items[i].PubDate); want.PubDate != "" && !ok {
+					t.Errorf("couldn't parse publication date %q", items[i].PubDate)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFeedRDFChannel(t *testing.T) {
+	feed := mustParseFeed(t, rdfDocument(""), "application/rdf+xml")
+	fields := []struct {
+		name      string
+		got, want string
+	}{
+		{"title", feed.Channel.Title, "Example RDF"},
+		{"link", feed.Channel.Link, "https://example.com/"},
+		{"description", feed.Channel.Description, "An RSS 1.0 feed"},
+		{"update period", feed.Channel.UpdatePeriod, "daily"},
+		{"update frequency", feed.Channel.UpdateFrequency, "2"},
+	}
+	for _, field := range fields {
+		if field.got != field.want {
+			t.Errorf("%s = %q, want %q", field.name, field.got, field.want)
+		}
+	}
+}
+
+// rdfDocument wraps the items in an RSS 1.0 feed, as siblings of its channel,
+// declaring the namespaces items use
+func rdfDocument(items string) string {
+	return `<?xml version="1.0" encoding="utf-8"?>
+<rdf:RDF
+	xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	xmlns="http://purl.org/rss/1.0/"
+	xmlns:dc="http://purl.org/dc/elements/1.1/"
+	xmlns:sy="http://purl.org/rss/1.0/modules/syndication/"
+	xmlns:content="http://purl.org/rss/1.0/modules/content/">
+	<channel rdf:about="https://example.com/">
+		<title>Example RDF</title>
+		<link>https://example.com/</link>
+		<description>An RSS 1.0 feed</description>
+		<sy:updatePeriod>daily</sy:updatePeriod>
+		<sy:updateFrequency>2</sy:updateFrequency>
+		<items>
+			<rdf:Seq>
+				<rdf:li rdf:resource="https://example.com/1"/>
+			</rdf:Seq>
+		</items>
+	</channel>
+	` + items + `
+</rdf:RDF>`
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// ANSI escape sequences styling the rendered text on terminals
+const (
+	ansiBold       = "\x1b[1m"
+	ansiBoldOff    = "\x1b[22m"
+	ansiItalic     = "\x1b[3m"
+	ansiItalicOff  = "\x1b[23m"
+	renderListStep = "  "
+)
+
+// isTerminal reports whether the file is a terminal, rather than a pipe or a regular file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// textRenderer renders HTML into plain text for terminals
+type textRenderer struct {
+	b strings.Builder
+	// styled reports whether bold and italic text are rendered with ANSI escape sequences
+	styled bool
+	// space reports whether a space is due before the next word
+	space bool
+	// newlines is the number of newlines ending the text rendered so far
+	newlines int
+	// lists holds the number of the next item of each open list, 0 for unordered ones
+	lists []int
+	// quotes is the depth of the open block quotes
+	quotes int
+	// pre is the depth of the open preformatted blocks
+	pre int
+	// bold and italic are the depths of the open bold and italic elements
+	bold, italic int
+	// links are the URLs of the links rendered as footnotes
+	links []string
+	// linkHref and linkStart are the URL and start of the text of the open link
+	linkHref  string
+	linkStart int
+}
+
+// renderHTML renders an HTML fragment into plain text: blocks are separated by blank lines,
+// list items are bulleted or numbered, links become numbered footnotes, images their alternative text,
+// and bold and italic text are styled with ANSI escape sequences when styled is true
+func renderHTML(s string, styled bool) string {
+	r := &textRenderer{styled: styled}
+	for _, tok := range tokenizeHTML(s) {
+		switch tok.Type {
+		case htmlText:
+			r.text(tok.Data)
+		case htmlStartTag, htmlSelfClosingTag:
+			r.start(tok)
+		case htmlEndTag:
+			r.end(tok.Name)
+		}
+	}
+	text := strings.TrimRightFunc(r.b.String(), unicode.IsSpace)
+	// the styles left open by unclosed elements don't leak into the rest of the terminal
+	if r.styled && r.bold > 0 {
+		text += ansiBoldOff
+	}
+	if r.styled && r.italic > 0 {
+		text += ansiItalicOff
+	}
+	if len(r.links) > 0 {
+		text += "\n"
+		for i, link := range r.links {
+			text += fmt.Sprintf("\n[%d] %s", i+1, link)
+		}
+	}
+	return text
+}
+
+func (r *textRenderer) start(tok htmlToken) {
+	switch tok.Name {
+	case "p", "div", "dl", "h1", "h2", "h3", "h4", "h5", "h6", "hr":
+		r.breakLines(2)
+	case "blockquote":
+		r.breakLines(2)
+		r.quotes++
+	case "pre":
+		r.breakLines(2)
+		r.pre++
+	case "ul", "ol":
+		if len(r.lists) == 0 {
+			r.breakLines(2)
+		}
+		next := 0
+		if tok.Name == "ol" {
+			next = 1
+		}
+		r.lists = append(r.lists, next)
+	case "li":
+		r.breakLines(1)
+		marker := "• "
+		if n := len(r.lists); n > 0 && r.lists[n-1] > 0 {
+			marker = fmt.Sprintf("%d. ", r.lists[n-1])
+			r.lists[n-1]++
+		}
+		// the marker is written after the indentation of the enclosing lists, and the text after it
+		r.indent(len(r.lists) - 1)
+		r.b.WriteString(marker)
+	case "dt", "dd", "br":
+		r.breakLines(1)
+	case "a":
+		r.linkHref, r.linkStart = stripTerminalControl(tok.attr("href")), r.b.Len()
+	case "img":
+		if alt := strings.TrimSpace(tok.attr("alt")); alt != "" {
+			r.text("[image: " + alt + "]")
+		}
+	}
+	switch tok.Name {
+	case "b", "strong", "h1", "h2", "h3", "h4", "h5", "h6", "dt":
+		r.bold++
+		if r.bold == 1 {
+			r.openStyle(ansiBold)
+		}
+	case "i", "em":
+		r.italic++
+		if r.italic == 1 {
+			r.openStyle(ansiItalic)
+		}
+	}
+}
+
+func (r *textRenderer) end(name string) {
+	// the styles end with the text they apply to, before the lines break, and with the outermost element
+	switch name {
+	case "b", "strong", "h1", "h2", "h3", "h4", "h5", "h6", "dt":
+		if r.bold == 1 {
+			r.style(ansiBoldOff)
+		}
+		r.bold = max(r.bold-1, 0)
+	case "i", "em":
+		if r.italic == 1 {
+			r.style(ansiItalicOff)
+		}
+		r.italic = max(r.italic-1, 0)
+	}
+	switch name {
+	case "p", "div", "dl", "h1", "h2", "h3", "h4", "h5", "h6":
+		r.breakLines(2)
+	case "blockquote":
+		r.breakLines(2)
+		r.quotes = max(r.quotes-1, 0)
+	case "pre":
+		r.breakLines(2)
+		r.pre = max(r.pre-1, 0)
+	case "ul", "ol":
+		if len(r.lists) == 0 {
+			return
+		}
+		r.lists = r.lists[:len(r.lists)-1]
+		if len(r.lists) == 0 {
+			r.breakLines(2)
+		}
+	case "a":
+		text := strings.TrimSpace(r.b.String()[r.linkStart:])
+		if href := r.linkHref; href != "" && href != text && !strings.HasPrefix(href, "#") {
+			r.links = append(r.links, href)
+			r.write(fmt.Sprintf("[%d]", len(r.links)))
+		}
+		r.linkHref = ""
+	}
+}
+
+// text renders a run of text, without its control characters, whose whitespace is collapsed
+// outside of preformatted blocks
+func (r *textRenderer) text(s string) {
+	// the text is decoded from entities, which may encode terminal escape sequences
+	s = stripTerminalControl(s)
+	if r.pre > 0 {
+		for i, line := range strings.Split(s, "\n") {
+			if i > 0 {
+				r.b.WriteString("\n")
+				r.newlines++
+			}
+			if line != "" {
+				r.write(line)
+			}
+		}
+		return
+	}
+	if s != "" && unicode.IsSpace(rune(s[0])) {
+		r.space = true
+	}
+	for _, word := range strings.Fields(s) {
+		if r.space && r.newlines == 0 && r.b.Len() > 0 && !strings.HasSuffix(r.b.String(), " ") {
+			r.b.WriteString(" ")
+		}
+		r.write(word)
+		r.space = true
+	}
+	r.space = s != "" && unicode.IsSpace(rune(s[len(s)-1]))
+}
+
+// write renders text as is, after the indentation of the line when it starts one
+func (r *textRenderer) write(s string) {
+	if r.newlines > 0 || r.b.Len() == 0 {
+		r.indent(len(r.lists))
+	}
+	r.b.WriteString(s)
+	r.newlines = 0
+}
+
+// indent starts a line with the indentation of the lists and the markers of the block quotes
+func (r *textRenderer) indent(lists int) {
+	if r.newlines == 0 && r.b.Len() > 0 {
+		return
+	}
+	r.b.WriteString(strings.Repeat("> ", r.quotes) + strings.Repeat(renderListStep, max(lists, 0)))
+	r.newlines = 0
+	r.space = false
+}
+
+// breakLines ends the current line, with blank lines up to n newlines, unless nothing was rendered yet
+func (r *textRenderer) breakLines(n int) {
+	if r.b.Len() == 0 {
+		return
+	}
+	for ; r.newlines < n; r.newlines++ {
+		r.b.WriteString("\n")
+	}
+	r.space = false
+}
+
+// openStyle writes an ANSI escape sequence starting a style when the output is styled,
+// after the space due before the next word, so that the space isn't styled
+func (r *textRenderer) openStyle(seq string) {
+	if r.styled && r.space && r.newlines == 0 && r.b.Len() > 0 {
+		r.b.WriteString(" ")
+		r.space = false
+	}
+	r.style(seq)
+}
+
+// style writes an ANSI escape sequence when the output is styled
+func (r *textRenderer) style(seq string) {
+	if r.styled {
+		r.b.WriteString(seq)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import "testing"
+
+func TestRenderHTML(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		styled bool
+		want   string
+	}{
+		{"plain text", "Hello, world", false, "Hello, world"},
+		{"whitespace collapsed", "  Hello,\n\t  world  ", false, "Hello, world"},
+		{"paragraphs", "<p>First</p><p>Second</p>", false, "First\n\nSecond"},
+		{"line breaks", "a<br>b<br/>c", false, "a\nb\nc"},
+		{"inline elements", "<p>Hello <span>big</span> <b>world</b>!</p>", false, "Hello big world!"},
+		{"headings", "<h1>Title</h1>Text", false, "Title\n\nText"},
+		{"unordered list", "<p>Intro</p><ul><li>one</li><li>two</li></ul><p>End</p>", false,
+			"Intro\n\n• one\n• two\n\nEnd"},
+		{"ordered list", "<ol><li>one</li><li>two</li><li>three</li></ol>", false, "1. one\n2. two\n3. three"},
+		{"nested lists", "<ul><li>one<ol><li>first</li><li>second</li></ol></li><li>two</li></ul>", false,
+			"• one\n  1. first\n  2. second\n• two"},
+		{"list item text wrapped", "<ul><li>a <b>bold</b> item</li></ul>", false, "• a bold item"},
+		{"definition list", "<dl><dt>Term</dt><dd>Definition</dd></dl>", false, "Term\nDefinition"},
+		{"blockquote", "<p>He said:</p><blockquote><p>Hello</p><p>world</p></blockquote><p>End</p>", false,
+			"He said:\n\n> Hello\n\n> world\n\nEnd"},
+		{"pre keeps whitespace", "<p>Code:</p><pre>func main() {\n\tfmt.Println(\"hi\")\n}</pre><p>End</p>", false,
+			"Code:\n\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n\nEnd"},
+		{"pre keeps entities decoded", "<pre>a &lt; b</pre>", false, "a < b"},
+		{"link footnotes", `See <a href="https://a.example">this</a> and <a href="https://b.example">that</a>.`, false,
+			"See this[1] and that[2].\n\n[1] https://a.example\n[2] https://b.example"},
+		{"link with its URL as text", `<a href="https://a.example">https://a.example</a>`, false, "https://a.example"},
+		{"anchor link", `<a href="#note">note</a>`, false, "note"},
+		{"link without href", `<a>text</a>`, false, "text"},
+		{"image", `<p>Look: <img src="a.png" alt="a cat"></p>`, false, "Look: [image: a cat]"},
+		{"image without alt", `<p>Look<img src="a.png"></p>`, false, "Look"},
+		{"plain bold and italic", "<p><b>bold</b> and <i>italic</i></p>", false, "bold and italic"},
+		{"styled bold and italic", "<p><b>bold</b> and <i>italic</i></p>", true,
+			"\x1b[1mbold\x1b[22m and \x1b[3mitalic\x1b[23m"},
+		{"styled space not styled", "a <strong>b</strong>", true, "a \x1b[1mb\x1b[22m"},
+		{"styled heading", "<h2>Title</h2>", true, "\x1b[1mTitle\x1b[22m"},
+		{"entity-encoded ESC", "a&#27;[2Jb", false, "a[2Jb"},
+		{"ESC in link", `<a href="https://a.example/&#27;[2J">x</a>`, false, "x[1]\n\n[1] https://a.example/[2J"},
+		{"ESC in pre", "<pre>a\x1b[31mb</pre>", false, "a[31mb"},
+		{"unclosed tags", "<p><b>bold", false, "bold"},
+		{"stray closing tags", "a</ul></ol></b></a>b", false, "ab"},
+		{"styled unclosed", "<p><b>bold <i>italic", true, "\x1b[1mbold \x1b[3mitalic\x1b[22m\x1b[23m"},
+		{"styled nested", "<b>a <strong>b</strong> c</b>", true, "\x1b[1ma b c\x1b[22m"},
+		{"styled stray closing tag", "a</b>", true, "a"},
+		{"empty", "", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderHTML(tt.input, tt.styled); got != tt.want {
+				t.Errorf("renderHTML(%q, %v) = %q, want %q", tt.input, tt.styled, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,15 +1,33 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
-	"html"
 	"io"
+	"mime"
 	"net/http"
-	"time"
+	"slices"
+	"strings"
+
+	"github.com/alnah/go-feedo/internal/database"
 )
 
+// feedAcceptHeader lists the media types of the feed formats fetchFeed can parse
+const feedAcceptHeader = "application/rss+xml, application/atom+xml, application/feed+json, " +
+	"application/rdf+xml, application/xml;q=0.9, text/xml;q=0.9, application/json;q=0.8, */*;q=0.1"
+
+// nonFeedMediaTypes are the media types of the responses which can't be feeds
+var nonFeedMediaTypes = []string{"text/html", "application/xhtml+xml", "application/pdf", "application/zip"}
+
+// nonFeedMediaTypePrefixes are the prefixes of the media types of the responses which can't be feeds
+var nonFeedMediaTypePrefixes = []string{"image/", "audio/", "video/", "font/"}
+
+// maxRedirects is the number of redirections fetchFeed follows before giving up
+const maxRedirects = 10
+
 // RSSFeed represents an RSS feed parsed from XML
 type RSSFeed struct {
 	// Channel contains metadata and items of the RSS feed
@@ -20,6 +38,8 @@ type RSSFeed struct {
 		Link string `xml:"link"`
 		// Description provides details about the feed
 		Description string `xml:"description"`
+		// RSSPollingHints tell how often the feed should be fetched
+		RSSPollingHints
 		// Item is the list of feed entries
 		Item []RSSItem `xml:"item"`
 	} `xml:"channel"`
@@ -27,6 +47,15 @@ type RSSFeed struct {
 
 // RSSItem represents an individual entry in an RSS feed
 type RSSItem struct {
+	// MediaTitle and MediaDescription are the Media RSS title and description of the item.
+	// They're declared before Title and Description, whose unqualified tags would catch them too,
+	// because the XML decoder fills the first field matching an element.
+	MediaTitle       string `xml:"http://search.yahoo.com/mrss/ title"`
+	MediaDescription string `xml:"http://search.yahoo.com/mrss/ description"`
+	// ITunesTitle is the iTunes title of the episode, declared before Title for the same reason
+	ITunesTitle string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd title"`
+	// GUID is the string uniquely identifying the item within its feed
+	GUID string `xml:"guid"`
 	// Title of the feed item
 	Title string `xml:"title"`
 	// Link to the full content of the item
@@ -35,37 +64,263 @@ type RSSItem struct {
 	Description string `xml:"description"`
 	// PubDate is the publication date of the item
 	PubDate string `xml:"pubDate"`
+	// Content is the full HTML content of the item, from content:encoded
+	Content string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	// ITunesAuthor is the iTunes name of the author of the episode,
+	// declared before Author for the same reason as MediaTitle
+	ITunesAuthor string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd author"`
+	// Author is the email address of the author of the item, often followed by their name in parentheses
+	Author string `xml:"author"`
+	// Creator is the name of the author of the item, from dc:creator
+	Creator string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	// Categories are the categories or tags the item belongs to
+	Categories []string `xml:"category"`
+	// CommentCount is the number of comments of the item, from slash:comments,
+	// declared before Comments for the same reason as MediaTitle
+	CommentCount string `xml:"http://purl.org/rss/1.0/modules/slash/ comments"`
+	// Comments is the URL of the comments page of the item
+	Comments string `xml:"comments"`
+	// Enclosures are the media files attached to the item, such as podcast episodes
+	Enclosures []RSSEnclosure `xml:"enclosure"`
+	// Duration is the iTunes duration of the episode, in seconds or in [HH:]MM:SS format
+	Duration string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	// Episode is the iTunes episode number
+	Episode string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd episode"`
+	// Season is the iTunes season number
+	Season string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd season"`
+	// MediaGroup holds the Media RSS thumbnails and contents of the item
+	MediaGroup
+	// MediaGroups are the Media RSS thumbnails and contents of the item grouped in media:group elements
+	MediaGroups []MediaGroup `xml:"http://search.yahoo.com/mrss/ group"`
+	// ITunesImage is the artwork of the episode
+	ITunesImage ITunesImage `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
+}
+
+// RSSEnclosure represents a media file attached to an RSS item
+type RSSEnclosure struct {
+	// URL is the location of the media file
+	URL string `xml:"url,attr"`
+	// Type is the MIME type of the media file
+	Type string `xml:"type,attr"`
+	// Length is the size of the media file in bytes, as declared by the feed
+	Length string `xml:"length,attr"`
+}
+
+// author returns the name of the author of the item, preferring dc:creator, then the name given
+// in parentheses after the email address of the author, then itunes:author, then the address itself
+func (item RSSItem) author() string {
+	if item.Creator != "" {
+		return item.Creator
+	}
+	if open, end := strings.Index(item.Author, "("), strings.LastIndex(item.Author, ")"); open >= 0 && end > open {
+		if name := strings.TrimSpace(item.Author[open+1 : end]); name != "" {
+			return name
+		}
+	}
+	if item.ITunesAuthor != "" {
+		return item.ITunesAuthor
+	}
+	return item.Author
+}
+
+// identifier returns the string identifying the item within its feed, which is
+// its GUID when the feed provides one, or its link otherwise
+func (item RSSItem) identifier() string {
+	if guid := strings.TrimSpace(item.GUID); guid != "" {
+		return guid
+	}
+	return strings.TrimSpace(item.Link)
+}
+
+// feedResponse holds a fetched feed along with the HTTP caching validators of the response
+type feedResponse struct {
+	// Feed is the parsed feed, nil when the feed wasn't modified
+	Feed *RSSFeed
+	// StatusCode is the HTTP status code of the response
+	StatusCode int
+	// NotModified reports whether the server answered 304 to the conditional request
+	NotModified bool
+	// ETag is the entity tag of the response, sent back as If-None-Match
+	ETag string
+	// LastModified is the last modification date of the response, sent back as If-Modified-Since
+	LastModified string
+	// PermanentURL is the new URL of the feed when the request was only redirected permanently,
+	// empty when it wasn't redirected, or when one of the redirections was temporary
+	PermanentURL string
 }
 
-// fetchFeed retrieves and parses an RSS feed from the specified URL using the provided context.
-func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+// statusError reports that the server answered a feed request with an unexpected HTTP status code
+type statusError struct {
+	StatusCode int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// fetchFeed retrieves and parses a feed using the provided context.
+// RSS 2.0, RSS 1.0 (RDF), Atom 1.0 and JSON Feed documents are supported, and normalized into an RSSFeed.
+// The request is conditional when validators from a previous fetch are stored on the feed.
+// The body is decoded while it's read, and reading stops with an error past maxBodySize bytes.
+func fetchFeed(ctx context.Context, feed database.Feed, maxBodySize int64) (*feedResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", feed.Url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("Error creating a new request: %q", err)
 	}
 	req.Header.Set("User-Agent", "go-feedo")
-	req.Header.Set("Content-Type", "application/rss+xml")
-	client := &http.Client{Timeout: 10 * time.Second}
+	req.Header.Set("Accept", feedAcceptHeader)
+	if feed.Etag != "" {
+		req.Header.Set("If-None-Match", feed.Etag)
+	}
+	if feed.LastModified != "" {
+		req.Header.Set("If-Modified-Since", feed.LastModified)
+	}
+	permanent := true
+	client := &http.Client{
+		Timeout: feedFetchTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			code := req.Response.StatusCode
+			if code != http.StatusMovedPermanently && code != http.StatusPermanentRedirect {
+				permanent = false
+			}
+			return nil
+		},
+	}
 	res, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("Error getting response: %q", err)
 	}
 	defer func() { _ = res.Body.Close() }()
-	byt, err := io.ReadAll(res.Body)
+	var permanentURL string
+	if finalURL := res.Request.URL.String(); permanent && finalURL != feed.Url {
+		permanentURL = finalURL
+	}
+	if res.StatusCode == http.StatusNotModified {
+		// a 304 may refresh the validators, otherwise the stored ones are still valid
+		feedRes := &feedResponse{
+			StatusCode:   res.StatusCode,
+			NotModified:  true,
+			ETag:         feed.Etag,
+			LastModified: feed.LastModified,
+			PermanentURL: permanentURL,
+		}
+		if etag := res.Header.Get("ETag"); etag != "" {
+			feedRes.ETag = etag
+		}
+		if lastModified := res.Header.Get("Last-Modified"); lastModified != "" {
+			feedRes.LastModified = lastModified
+		}
+		return feedRes, nil
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, &statusError{StatusCode: res.StatusCode}
+	}
+	contentType := res.Header.Get("Content-Type")
+	if err = checkFeedContentType(contentType); err != nil {
+		return nil, err
+	}
+	if res.ContentLength > maxBodySize {
+		return nil, fmt.Errorf("response body too large: %d bytes, the limit is %d bytes", res.ContentLength, maxBodySize)
+	}
+	rssFeed, err := parseFeed(http.MaxBytesReader(nil, res.Body, maxBodySize), contentType)
 	if err != nil {
-		return nil, fmt.Errorf("Error reading response body: %q", err)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, fmt.Errorf("response body too large: the limit is %d bytes", maxBodySize)
+		}
+		return nil, err
 	}
-	var rssFeed RSSFeed
-	if err = xml.Unmarshal(byt, &rssFeed); err != nil {
-		return nil, fmt.Errorf("Error unmarshalling: %q", err)
+	rssFeed.normalize()
+	return &feedResponse{
+		Feed:         rssFeed,
+		StatusCode:   res.StatusCode,
+		ETag:         res.Header.Get("ETag"),
+		LastModified: res.Header.Get("Last-Modified"),
+		PermanentURL: permanentURL,
+	}, nil
+}
+
+// checkFeedContentType rejects the responses whose content type can't be a feed,
+// such as web pages or media files, the feeds served with a generic content type being accepted
+func checkFeedContentType(contentType string) error {
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("not a feed: invalid content type %q", contentType)
 	}
-	var toUnescape []string
-	toUnescape = append(toUnescape, rssFeed.Channel.Title, rssFeed.Channel.Description)
-	for _, item := range rssFeed.Channel.Item {
-		toUnescape = append(toUnescape, item.Description, item.Title)
+	if slices.Contains(nonFeedMediaTypes, mediaType) ||
+		slices.ContainsFunc(nonFeedMediaTypePrefixes, func(prefix string) bool {
+			return strings.HasPrefix(mediaType, prefix)
+		}) {
+		return fmt.Errorf("not a feed: unexpected content type %q", mediaType)
 	}
-	for i := range toUnescape {
-		toUnescape[i] = html.UnescapeString(toUnescape[i])
+	return nil
+}
+
+// parseFeed decodes a feed document while reading it, detects its format from its content type
+// and its root element, and normalizes it into an RSSFeed whatever the source format is.
+// XML documents in the common legacy charsets are decoded into UTF-8.
+func parseFeed(r io.Reader, contentType string) (*RSSFeed, error) {
+	br := bufio.NewReader(r)
+	if isJSONFeed(br, contentType) {
+		return parseJSONFeed(br)
+	}
+	// the charset of the HTTP Content-Type takes precedence over the encoding of the XML declaration,
+	// which is used when the HTTP charset is missing or unsupported
+	var dec *xml.Decoder
+	var decoded io.Reader
+	if _, params, err := mime.ParseMediaType(contentType); err == nil && params["charset"] != "" {
+		decoded, _ = charsetReader(params["charset"], br)
+	}
+	if decoded != nil {
+		dec = xml.NewDecoder(decoded)
+		dec.CharsetReader = utf8CharsetReader
+	} else {
+		dec = xml.NewDecoder(br)
+		dec.CharsetReader = charsetReader
+	}
+	root, err := rootElement(dec)
+	if err != nil {
+		return nil, err
+	}
+	switch root.Name.Local {
+	case "rss":
+		var rssFeed RSSFeed
+		if err = dec.DecodeElement(&rssFeed, &root); err != nil {
+			return nil, fmt.Errorf("Error unmarshalling: %w", err)
+		}
+		return &rssFeed, nil
+	case "feed":
+		var atomFeed AtomFeed
+		if err = dec.DecodeElement(&atomFeed, &root); err != nil {
+			return nil, fmt.Errorf("Error unmarshalling: %w", err)
+		}
+		return atomFeed.toRSSFeed(), nil
+	case "RDF":
+		var rdfFeed RDFFeed
+		if err = dec.DecodeElement(&rdfFeed, &root); err != nil {
+			return nil, fmt.Errorf("Error unmarshalling: %w", err)
+		}
+		return rdfFeed.toRSSFeed(), nil
+	default:
+		return nil, fmt.Errorf("unsupported feed format: <%s>", root.Name.Local)
+	}
+}
+
+// rootElement reads the decoder up to the first XML element of the document, and returns it
+func rootElement(dec *xml.Decoder) (xml.StartElement, error) {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return xml.StartElement{}, fmt.Errorf("couldn't find root element: %w", err)
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start, nil
+		}
 	}
-	return &rssFeed, nil
 }
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// wordPressFeed is an RSS 2.0 feed as WordPress serves it, with the slash:comments
+// count following the comments URL in every item
+const wordPressFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"
+	xmlns:content="http://purl.org/rss/1.0/modules/content/"
+	xmlns:wfw="http://wellformedweb.org/CommentAPI/"
+	xmlns:dc="http://purl.org/dc/elements/1.1/"
+	xmlns:atom="http://www.w3.org/2005/Atom"
+	xmlns:sy="http://purl.org/rss/1.0/modules/syndication/"
+	xmlns:slash="http://purl.org/rss/1.0/modules/slash/">
+<channel>
+	<title>Example Blog</title>
+	<atom:link href="https://example.com/feed/" rel="self" type="application/rss+xml" />
+	<link>https://example.com</link>
+	<description>Just another WordPress site</description>
+	<sy:updatePeriod>hourly</sy:updatePeriod>
+	<sy:updateFrequency>1</sy:updateFrequency>
+	<item>
+		<title>Hello world!</title>
+		<link>https://example.com/hello-world/</link>
+		<comments>https://example.com/hello-world/#respond</comments>
+		<dc:creator><![CDATA[admin]]></dc:creator>
+		<pubDate>Wed, 02 Oct 2002 13:00:00 +0000</pubDate>
+		<category><![CDATA[News]]></category>
+		<category><![CDATA[Uncategorized]]></category>
+		<guid isPermaLink="false">https://example.com/?p=1</guid>
+		<description><![CDATA[Welcome to WordPress &#8230;]]></description>
+		<content:encoded><![CDATA[<p>Welcome to WordPress. This is your first post.</p>]]></content:encoded>
+		<wfw:commentRss>https://example.com/hello-world/feed/</wfw:commentRss>
+		<slash:comments>3</slash:comments>
+	</item>
+</channel>
+</rss>`
+
+// podcastFeed is an RSS 2.0 feed with the iTunes elements podcast hosts add to every item
+const podcastFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+<channel>
+	<title>Example Podcast</title>
+	<link>https://example.com/podcast</link>
+	<item>
+		<title>Episode 1: Pilot</title>
+		<author>host@example.com (Jane Host)</author>
+		<itunes:title>Pilot</itunes:title>
+		<itunes:author>Example Network</itunes:author>
+		<guid>episode-1</guid>
+		<enclosure url="https://example.com/1.mp3" type="audio/mpeg" length="1234"/>
+		<itunes:duration>01:02:03</itunes:duration>
+		<itunes:episode>1</itunes:episode>
+	</item>
+	<item>
+		<itunes:title>Bonus</itunes:title>
+		<itunes:author>Example Network</itunes:author>
+		<guid>bonus</guid>
+	</item>
+</channel>
+</rss>`
+
+func TestParseFeedRSSItems(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     []RSSItem
+		authors  []string
+	}{
+		{
+			name:     "WordPress",
+			document: wordPressFeed,
+			want: []RSSItem{{
+				GUID:        "https://example.com/?p=1",
+				Title:       "Hello world!",
+				Link:        "https://example.com/hello-world/",
+				Description: "Welcome to WordPress …",
+				PubDate:     "Wed, 02 Oct 2002 13:00:00 +0000",
+				Content:     "<p>Welcome to WordPress. This is your first post.</p>",
+				Creator:     "admin",
+				Categories:  []string{"News", "Uncategorized"},
+				Comments:    "https://example.com/hello-world/#respond",
+			}},
+			authors: []string{"admin"},
+		},
+		{
+			name:     "podcast",
+			document: podcastFeed,
+			want: []RSSItem{
+				{
+					GUID:     "episode-1",
+					Title:    "Episode 1: Pilot",
+					Author:   "host@example.com (Jane Host)",
+					Duration: "01:02:03",
+					Episode:  "1",
+				},
+				{
+					GUID:  "bonus",
+					Title: "Bonus",
+				},
+			},
+			authors: []string{"Jane Host", "Example Network"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed := mustParseFeed(t, tt.document, "application/rss+xml")
+			items := feed.Channel.Item
+			if len(items) != len(tt.want) {
+				t.Fatalf("got %d items, want %d", len(items), len(tt.want))
+			}
+			for i, want := range tt.want {
+				checkItem(t, items[i], want)
+				if got := items[i].author(); got != tt.authors[i] {
+					t.Errorf("item %d: author() = %q, want %q", i, got, tt.authors[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseFeedFormats(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		document    string
+		wantTitle   string
+		wantErr     string
+	}{
+		{"RSS 2.0", "application/rss+xml", `<rss version="2.0"><channel><title>RSS</title></channel></rss>`, "RSS", ""},
+		{"RSS with prolog", "text/xml",
+			`<?xml version="1.0"?><!-- comment --><!DOCTYPE rss><rss><channel><title>RSS</title></channel></rss>`, "RSS", ""},
+		{"RSS with byte order mark", "text/xml", "\ufeff<rss><channel><title>RSS</title></channel></rss>", "RSS", ""},
+		{"Atom", "application/atom+xml", `<feed xmlns="http://www.w3.org/2005/Atom"><title>Atom</title></feed>`, "Atom", ""},
+		{"Atom served as RSS", "application/rss+xml",
+			`<feed xmlns="http://www.w3.org/2005/Atom"><title>Atom</title></feed>`, "Atom", ""},
+		{"Atom served as text", "text/plain; charset=utf-8",
+			`<?xml version="1.0"?><feed xmlns="http://www.w3.org/2005/Atom"><title>Atom</title></feed>`, "Atom", ""},
+		{"RDF", "application/rdf+xml",
+			`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/">` +
+				`<channel><title>RDF</title></channel></rdf:RDF>`, "RDF", ""},
+		{"JSON Feed", "application/feed+json",
+			`{"version": "https://jsonfeed.org/version/1.1", "title": "JSON"}`, "JSON", ""},
+		{"JSON Feed served as JSON", "application/json",
+			` {"version": "https://jsonfeed.org/version/1", "title": "JSON"}`, "JSON", ""},
+		{"JSON Feed served as XML", "application/xml",
+			"\n{\"version\": \"https://jsonfeed.org/version/1.1\", \"title\": \"JSON\"}", "JSON", ""},
+		{"JSON Feed without content type", "",
+			`{"version": "https://jsonfeed.org/version/1.1", "title": "JSON"}`, "JSON", ""},
+		{"JSON document", "application/json", `{"version": "2.0", "title": "JSON"}`, "", "unsupported JSON document"},
+		{"JSON array", "application/json", `[{"title": "JSON"}]`, "", "couldn't find root element"},
+		{"HTML page", "text/xml", `<html><head><title>Page</title></head></html>`, "", "unsupported feed format: <html>"},
+		{"OPML", "text/xml",
+			`<opml version="2.0"><head><title>OPML</title></head></opml>`, "", "unsupported feed format: <opml>"},
+		{"empty", "text/xml", ``, "", "couldn't find root element"},
+		{"malformed", "application/rss+xml", `<rss><channel><title>RSS</channel></rss>`, "", "Error unmarshalling"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, err := parseFeed(strings.NewReader(tt.document), tt.contentType)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseFeed() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseFeed() error = %v", err)
+			}
+			if feed.Channel.Title != tt.wantTitle {
+				t.Errorf("title = %q, want %q", feed.Channel.Title, tt.wantTitle)
+			}
+		})
+	}
+}
+
+// mustParseFeed parses and normalizes the document as fetchFeed does
+func mustParseFeed(t *testing.T, document, contentType string) *RSSFeed {
+	t.Helper()
+	feed, err := parseFeed(strings.NewReader(document), contentType)
+	if err != nil {
+		t.Fatalf("parseFeed() error = %v", err)
+	}
+	feed.normalize()
+	return feed
+}
+
+// checkItem compares the fields of the item persisted by the aggregator
+func checkItem(t *testing.T, got, want RSSItem) {
+	t.Helper()
+	fields := []struct {
+		name      string
+		got, want string
+	}{
+		{"GUID", got.GUID, want.GUID},
+		{"Title", got.Title, want.Title},
+		{"Link", got.Link, want.Link},
+		{"Description", got.Description, want.Description},
+		{"PubDate", got.PubDate, want.PubDate},
+		{"Content", got.Content, want.Content},
+		{"Author", got.Author, want.Author},
+		{"Creator", got.Creator, want.Creator},
+		{"Comments", got.Comments, want.Comments},
+		{"Duration", got.Duration, want.Duration},
+		{"Episode", got.Episode, want.Episode},
+		{"Categories", strings.Join(got.Categories, "|"), strings.Join(want.Categories, "|")},
+	}
+	for _, field := range fields {
+		if field.got != field.want {
+			t.Errorf("%s of item %q = %q, want %q", field.name, got.identifier(), field.got, field.want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"html"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// sanitizedElements maps the elements kept by sanitizeHTML to the attributes they keep
+var sanitizedElements = map[string][]string{
+	"a": {"href"}, "img": {"src", "alt"},
+	"p": nil, "div": nil, "br": nil, "hr": nil, "blockquote": nil, "pre": nil, "code": nil,
+	"ul": nil, "ol": nil, "li": nil, "dl": nil, "dt": nil, "dd": nil,
+	"h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil,
+	"b": nil, "strong": nil, "i": nil, "em": nil,
+}
+
+// droppedElements are the elements removed by sanitizeHTML along with their content
+var droppedElements = []string{
+	"script", "style", "noscript", "template", "iframe", "object", "embed", "svg", "math", "form", "head", "title",
+}
+
+// safeURLSchemes are the schemes of the URLs kept in links and images, relative URLs being kept too
+var safeURLSchemes = []string{"http", "https", "mailto"}
+
+// sanitizeHTML keeps the harmless structure and text of an HTML fragment, and drops everything else:
+// scripts, styles, embedded frames, tracking pixels, unknown elements, attributes and unsafe URLs.
+// The result is well-formed, with every element it opens closed.
+func sanitizeHTML(s string) string {
+	var b strings.Builder
+	var open []string
+	dropping := ""
+	for _, tok := range tokenizeHTML(s) {
+		if dropping != "" {
+			if tok.Type == htmlEndTag && tok.Name == dropping {
+				dropping = ""
+			}
+			continue
+		}
+		switch tok.Type {
+		case htmlText:
+			b.WriteString(html.EscapeString(stripTerminalControl(tok.Data)))
+		case htmlStartTag, htmlSelfClosingTag:
+			if slices.Contains(droppedElements, tok.Name) {
+				if tok.Type == htmlStartTag && !slices.Contains(htmlVoidElements, tok.Name) {
+					dropping = tok.Name
+				}
+				continue
+			}
+			attrs, ok := sanitizedElements[tok.Name]
+			if !ok || tok.Name == "img" && isTrackingPixel(tok) {
+				continue
+			}
+			b.WriteString("<" + tok.Name)
+			for _, key := range attrs {
+				val := stripTerminalControl(tok.attr(key))
+				if val == "" || (key == "href" || key == "src") && !isSafeURL(val) {
+					continue
+				}
+				b.WriteString(" " + key + `="` + html.EscapeString(val) + `"`)
+			}
+			b.WriteString(">")
+			if tok.Type == htmlStartTag && !slices.Contains(htmlVoidElements, tok.Name) {
+				open = append(open, tok.Name)
+			}
+		case htmlEndTag:
+			// closing an element closes the ones left open inside it, and stray closing tags are dropped
+			i := len(open) - 1
+			for i >= 0 && open[i] != tok.Name {
+				i--
+			}
+			if i < 0 {
+				continue
+			}
+			for len(open) > i {
+				b.WriteString("</" + open[len(open)-1] + ">")
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	for len(open) > 0 {
+		b.WriteString("</" + open[len(open)-1] + ">")
+		open = open[:len(open)-1]
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// isTrackingPixel reports whether an image is too small to be anything but a tracking pixel
+func isTrackingPixel(tok htmlToken) bool {
+	for _, key := range []string{"width", "height"} {
+		if val := strings.TrimSuffix(strings.TrimSpace(tok.attr(key)), "px"); val == "0" || val == "1" {
+			return true
+		}
+	}
+	return false
+}
+
+// isSafeURL reports whether the URL is relative, or uses one of the safe schemes
+func isSafeURL(raw string) bool {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return false
+	}
+	return u.Scheme == "" || slices.Contains(safeURLSchemes, strings.ToLower(u.Scheme))
+}
+
+// stripTerminalControl removes the C0 and C1 control characters from decoded text, such as the ESC starting
+// terminal escape sequences, except for newlines and tabs. The other whitespace control characters become spaces,
+// so that the words they separate stay apart.
+func stripTerminalControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\t' || !unicode.IsControl(r):
+			return r
+		case unicode.IsSpace(r):
+			return ' '
+		}
+		return -1
+	}, s)
+}
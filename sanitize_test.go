@@ -0,0 +1,77 @@
+package main
+
+import "testing"
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"plain text", "Hello, world", "Hello, world"},
+		{"text escaped", `1 < 2 & "3" > 0`, "1 &lt; 2 &amp; &#34;3&#34; &gt; 0"},
+		{"kept structure", "<p>Hello <b>bold</b> and <em>em</em></p><ul><li>one</li></ul>",
+			"<p>Hello <b>bold</b> and <em>em</em></p><ul><li>one</li></ul>"},
+		{"uppercase tags", "<P>Hello<BR/>world</P>", "<p>Hello<br>world</p>"},
+		{"unknown elements unwrapped", "<span class=\"x\">Hello</span> <font color=red>world</font>", "Hello world"},
+		{"attributes dropped", `<p class="intro" style="color: red" id="a">Hello</p>`, "<p>Hello</p>"},
+		{"event handlers", `<img src="a.png" onerror="alert(1)" onload=alert(2)><p onclick="alert(3)">x</p>`,
+			`<img src="a.png"><p>x</p>`},
+		{"link kept", `<a href="https://example.com/?a=1&amp;b=2" title="t">link</a>`,
+			`<a href="https://example.com/?a=1&amp;b=2">link</a>`},
+		{"relative link kept", `<a href="/posts/1">link</a>`, `<a href="/posts/1">link</a>`},
+		{"mailto kept", `<a href="mailto:me@example.com">me</a>`, `<a href="mailto:me@example.com">me</a>`},
+		{"unquoted attribute", `<a href=https://example.com>link</a>`, `<a href="https://example.com">link</a>`},
+		{"quote in attribute", `<img src="a.png" alt='say "hi"'>`, `<img src="a.png" alt="say &#34;hi&#34;">`},
+		{"javascript URL", `<a href="javascript:alert(1)">x</a>`, "<a>x</a>"},
+		{"javascript URL uppercase", `<a href="JaVaScRiPt:alert(1)">x</a>`, "<a>x</a>"},
+		{"javascript URL leading space", `<a href="  javascript:alert(1)">x</a>`, "<a>x</a>"},
+		{"javascript URL with tab", "<a href=\"java\tscript:alert(1)\">x</a>", "<a>x</a>"},
+		{"javascript URL with encoded tab", `<a href="java&#x09;script:alert(1)">x</a>`, "<a>x</a>"},
+		{"javascript URL with encoded newline", `<a href="java&#10;script:alert(1)">x</a>`, "<a>x</a>"},
+		{"javascript URL with control character", `<a href="&#1;javascript:alert(1)">x</a>`, "<a>x</a>"},
+		{"javascript URL encoded", `<a href="&#106;&#x61;vascript:alert(1)">x</a>`, "<a>x</a>"},
+		{"javascript URL encoded colon", `<a href="javascript&colon;alert(1)">x</a>`, "<a>x</a>"},
+		{"data URL", `<img src="data:image/svg+xml;base64,PHN2Zz4=" alt="x">`, `<img alt="x">`},
+		{"vbscript URL", `<a href="vbscript:msgbox(1)">x</a>`, "<a>x</a>"},
+		{"script", "<p>a</p><script>alert(1)</script><p>b</p>", "<p>a</p><p>b</p>"},
+		{"script uppercase", "a<SCRIPT>alert(1)</ScRiPt>b", "ab"},
+		{"script with markup", `a<script>document.write("<p>x</p>")</script>b`, "ab"},
+		{"script unclosed", "a<script>alert(1)", "a"},
+		{"style", "<style>p { color: red }</style>Hello", "Hello"},
+		{"title", "<title>Title</title>Hello", "Hello"},
+		{"textarea content escaped", "<textarea><script>alert(1)</script></textarea>",
+			"&lt;script&gt;alert(1)&lt;/script&gt;"},
+		{"iframe", `<iframe src="https://example.com">fallback</iframe>after`, "after"},
+		{"svg", `<svg><script>alert(1)</script><a href="x">y</a></svg>after`, "after"},
+		{"nested dropped", "<form><form>a</form>b</form>c", "bc"},
+		{"comment", "a<!-- <script>alert(1)</script> -->b", "ab"},
+		{"comment unclosed", "a<!-- b", "a"},
+		{"doctype", "<!DOCTYPE html><p>a</p>", "<p>a</p>"},
+		{"unclosed tags", "<p><b>bold", "<p><b>bold</b></p>"},
+		{"stray closing tags", "a</div></p>b", "ab"},
+		{"misnested tags", "<b><i>x</b>y</i>", "<b><i>x</i></b>y"},
+		{"void elements", "a<br>b<hr>c<img src=\"a.png\">", `a<br>b<hr>c<img src="a.png">`},
+		{"tracking pixel", `<p>a<img src="p.gif" width="1" height="1"></p>`, "<p>a</p>"},
+		{"tracking pixel in pixels", `<img src="p.gif" width="0px">`, ""},
+		{"lone less-than", "a < b <= c", "a &lt; b &lt;= c"},
+		{"double less-than", "<<script>alert(1)//<</script>", "&lt;"},
+		{"broken tag name", "<scr<script>ipt>alert(1)</script>", "ipt&gt;alert(1)"},
+		{"unterminated tag", `a<p class="x`, "a<p></p>"},
+		{"entity-encoded ESC", "<p>&#27;[31mred&#x1b;[0m</p>", "<p>[31mred[0m</p>"},
+		{"raw ESC", "\x1b]0;title\x07text", "]0;titletext"},
+		{"ESC in attribute", `<img src="a.png" alt="&#27;[2Jalt">`, `<img src="a.png" alt="[2Jalt">`},
+		{"C1 control", "a\u009b31mb", "a31mb"},
+		{"entity-encoded C1 control read as windows-1252", "a&#x9b;31mb", "a›31mb"},
+		{"whitespace control", "a&#x0c;b&#x0d;c", "a b c"},
+		{"newlines and tabs kept", "<pre>a\n\tb</pre>", "<pre>a\n\tb</pre>"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeHTML(tt.input); got != tt.want {
+				t.Errorf("sanitizeHTML(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
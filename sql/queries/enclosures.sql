@@ -0,0 +1,64 @@
+-- name: CreateEnclosure :one
+INSERT INTO enclosures (id, created_at, updated_at, post_id, url, mime_type, length, duration_seconds, episode, season,
+    download_requested_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+ON CONFLICT (post_id, url) DO UPDATE
+SET updated_at = EXCLUDED.updated_at,
+    mime_type = EXCLUDED.mime_type,
+    length = EXCLUDED.length,
+    duration_seconds = EXCLUDED.duration_seconds,
+    episode = EXCLUDED.episode,
+    season = EXCLUDED.season,
+    download_requested_at = COALESCE(enclosures.download_requested_at, EXCLUDED.download_requested_at)
+RETURNING *;
+
+-- name: GetEnclosuresForPost :many
+SELECT * FROM enclosures
+WHERE post_id = $1
+ORDER BY created_at;
+
+-- name: GetEnclosuresForPostURL :many
+SELECT enclosures.* FROM enclosures
+JOIN posts ON enclosures.post_id = posts.id
+WHERE posts.url = $1
+ORDER BY enclosures.created_at;
+
+-- name: ClaimEnclosureToDownload :one
+UPDATE enclosures
+SET updated_at = $1,
+    lease_expires_at = $2,
+    download_attempts = download_attempts + 1
+WHERE id = (
+    SELECT id FROM enclosures
+    WHERE download_requested_at IS NOT NULL AND downloaded_at IS NULL
+    AND download_attempts < $3
+    AND (lease_expires_at IS NULL OR lease_expires_at < $1)
+    ORDER BY download_requested_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: ClaimEnclosure :one
+UPDATE enclosures
+SET updated_at = $2,
+    lease_expires_at = $3
+WHERE id = $1
+AND (lease_expires_at IS NULL OR lease_expires_at < $2)
+RETURNING *;
+
+-- name: ReleaseEnclosureLease :exec
+UPDATE enclosures
+SET updated_at = $2,
+    lease_expires_at = $3
+WHERE id = $1;
+
+-- name: MarkEnclosureDownloaded :exec
+UPDATE enclosures
+SET updated_at = $2,
+    downloaded_at = $2,
+    file_path = $3,
+    sha256 = $4,
+    download_requested_at = NULL,
+    lease_expires_at = NULL
+WHERE id = $1;
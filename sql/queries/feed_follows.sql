@@ -26,3 +26,11 @@ DELETE FROM feed_follows
 WHERE feed_follows.user_id = $1
 AND feed_follows.feed_id = (SELECT id FROM feeds WHERE feeds.url = $2);
 -- 
+
+-- name: MoveFeedFollows :exec
+UPDATE feed_follows
+SET updated_at = sqlc.arg(updated_at),
+    feed_id = sqlc.arg(new_feed_id)
+WHERE feed_id = sqlc.arg(old_feed_id)
+AND user_id NOT IN (SELECT user_id FROM feed_follows WHERE feed_id = sqlc.arg(new_feed_id));
+--
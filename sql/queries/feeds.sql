@@ -13,10 +13,111 @@ WHERE url = $1;
 -- name: MarkFeedFetched :exec
 UPDATE feeds
 SET updated_at = $2,
-    last_fetched_at = $2
+    last_fetched_at = $2,
+    lease_expires_at = NULL
 WHERE id = $1;
 
--- name: GetNextFeedToFetch :one
+-- name: ReleaseFeedLease :exec
+UPDATE feeds
+SET updated_at = $2,
+    lease_expires_at = NULL
+WHERE id = $1;
+
+-- name: ClaimFeedsToFetch :many
+UPDATE feeds
+SET updated_at = $1,
+    lease_expires_at = $2
+WHERE id IN (
+    SELECT id FROM feeds
+    WHERE (lease_expires_at IS NULL OR lease_expires_at < $1)
+    AND (next_fetch_at IS NULL OR next_fetch_at <= $1)
+    AND disabled_at IS NULL
+    ORDER BY last_fetched_at NULLS FIRST
+    LIMIT $3
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: UpdateFeedValidators :exec
+UPDATE feeds
+SET updated_at = $2,
+    etag = $3,
+    last_modified = $4
+WHERE id = $1;
+
+-- name: RecordFeedSuccess :exec
+UPDATE feeds
+SET updated_at = $2,
+    error_count = 0,
+    last_error = '',
+    last_status_code = $3,
+    next_fetch_at = $4,
+    failing_since = NULL,
+    gone_count = 0,
+    adaptive_interval_seconds = $5,
+    poll_min_delay_seconds = $6,
+    poll_skip_hours = $7,
+    poll_skip_days = $8
+WHERE id = $1;
+
+-- name: RecordFeedFailure :exec
+UPDATE feeds
+SET updated_at = $2,
+    error_count = error_count + 1,
+    last_error = $3,
+    last_status_code = $4,
+    next_fetch_at = $5,
+    failing_since = COALESCE(failing_since, $2),
+    disabled_at = $6,
+    gone_count = $7
+WHERE id = $1;
+
+-- name: GetBrokenFeeds :many
 SELECT * FROM feeds
-ORDER BY last_fetched_at NULLS FIRST
+WHERE disabled_at IS NOT NULL OR error_count > 0
+ORDER BY disabled_at NULLS LAST, error_count DESC;
+
+-- name: ReviveFeed :one
+UPDATE feeds
+SET updated_at = $2,
+    error_count = 0,
+    last_error = '',
+    last_status_code = 0,
+    next_fetch_at = NULL,
+    failing_since = NULL,
+    disabled_at = NULL,
+    gone_count = 0
+WHERE url = $1
+RETURNING *;
+
+-- name: UpdateFeedURL :exec
+UPDATE feeds
+SET updated_at = $2,
+    url = $3
+WHERE id = $1;
+
+-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1;
+
+-- name: SetFeedCustomInterval :one
+UPDATE feeds
+SET updated_at = $2,
+    custom_interval_seconds = $3,
+    next_fetch_at = NULL
+WHERE url = $1
+RETURNING *;
+
+-- name: GetNextFetchAt :one
+SELECT next_fetch_at FROM feeds
+WHERE disabled_at IS NULL
+AND (lease_expires_at IS NULL OR lease_expires_at < sqlc.arg(now)::timestamp)
+ORDER BY next_fetch_at NULLS FIRST
 LIMIT 1;
+
+-- name: SetFeedFullContent :one
+UPDATE feeds
+SET updated_at = $2,
+    full_content = $3
+WHERE url = $1
+RETURNING *;
@@ -1,9 +1,37 @@
 -- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, guid, content_hash,
+    sanitized_description, content, author, categories, comments_url, image_url, content_requested_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET updated_at = EXCLUDED.updated_at,
+    title = EXCLUDED.title,
+    url = EXCLUDED.url,
+    description = EXCLUDED.description,
+    content_hash = EXCLUDED.content_hash,
+    sanitized_description = EXCLUDED.sanitized_description,
+    content = EXCLUDED.content,
+    author = EXCLUDED.author,
+    categories = EXCLUDED.categories,
+    comments_url = EXCLUDED.comments_url,
+    image_url = EXCLUDED.image_url,
+    content_requested_at = EXCLUDED.content_requested_at,
+    content_attempts = 0,
+    revision = posts.revision + 1
+WHERE posts.content_hash <> EXCLUDED.content_hash
 RETURNING *;
 --
 
+-- name: AdoptPostGUID :exec
+UPDATE posts
+SET guid = sqlc.arg(guid)
+WHERE id = (
+    SELECT id FROM posts
+    WHERE feed_id = sqlc.arg(feed_id) AND url = sqlc.arg(url) AND guid = url
+    AND NOT EXISTS (SELECT 1 FROM posts WHERE feed_id = sqlc.arg(feed_id) AND guid = sqlc.arg(guid))
+    LIMIT 1
+);
+--
+
 -- name: GetPostsForUser :many
 SELECT posts.*, feeds.name AS feed_name FROM posts
 JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
@@ -12,3 +40,70 @@ WHERE feed_follows.user_id = $1
 ORDER BY posts.published_at DESC
 LIMIT $2;
 --
+
+-- name: MovePosts :exec
+UPDATE posts
+SET updated_at = sqlc.arg(updated_at),
+    feed_id = sqlc.arg(new_feed_id)
+WHERE feed_id = sqlc.arg(old_feed_id)
+AND guid NOT IN (SELECT guid FROM posts WHERE feed_id = sqlc.arg(new_feed_id));
+--
+
+-- name: GetFeedPublishingStats :one
+SELECT
+    COUNT(*)::int AS post_count,
+    COALESCE(EXTRACT(EPOCH FROM MAX(published_at) - MIN(published_at)), 0)::bigint AS span_seconds
+FROM (
+    SELECT DISTINCT published_at FROM posts
+    WHERE feed_id = $1 AND published_at IS NOT NULL
+    ORDER BY published_at DESC
+    LIMIT $2
+) AS recent_posts;
+--
+
+-- name: ClaimPostToFetchContent :one
+UPDATE posts
+SET content_lease_expires_at = sqlc.arg(lease_expires_at),
+    content_attempts = content_attempts + 1
+WHERE id = (
+    SELECT id FROM posts
+    WHERE content_requested_at IS NOT NULL
+    AND content_attempts < sqlc.arg(max_attempts)
+    AND (content_lease_expires_at IS NULL OR content_lease_expires_at < sqlc.arg(now))
+    ORDER BY content_requested_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+--
+
+-- name: ReleasePostContentLease :exec
+UPDATE posts
+SET content_lease_expires_at = $2
+WHERE id = $1;
+--
+
+-- name: UpdatePostContent :exec
+UPDATE posts
+SET updated_at = $2,
+    content = $3,
+    content_requested_at = NULL,
+    content_lease_expires_at = NULL
+WHERE id = $1;
+--
+
+-- name: GetPostsToNormalize :many
+SELECT id, title, description FROM posts
+WHERE NOT text_normalized
+LIMIT $1;
+--
+
+-- name: NormalizePostText :exec
+UPDATE posts
+SET title = $2,
+    description = $3,
+    sanitized_description = $4,
+    content_hash = $5,
+    text_normalized = true
+WHERE id = $1;
+--
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN poll_min_delay_seconds INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE feeds ADD COLUMN poll_skip_hours INTEGER[] NOT NULL DEFAULT '{}';
+ALTER TABLE feeds ADD COLUMN poll_skip_days TEXT[] NOT NULL DEFAULT '{}';
+
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN poll_skip_days;
+ALTER TABLE feeds DROP COLUMN poll_skip_hours;
+ALTER TABLE feeds DROP COLUMN poll_min_delay_seconds;
@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE enclosures ADD COLUMN download_requested_at TIMESTAMP;
+ALTER TABLE enclosures ADD COLUMN lease_expires_at TIMESTAMP;
+ALTER TABLE enclosures ADD COLUMN download_attempts INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX enclosures_download_requested_at_idx ON enclosures (download_requested_at)
+WHERE download_requested_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX enclosures_download_requested_at_idx;
+ALTER TABLE enclosures DROP COLUMN download_attempts;
+ALTER TABLE enclosures DROP COLUMN lease_expires_at;
+ALTER TABLE enclosures DROP COLUMN download_requested_at;
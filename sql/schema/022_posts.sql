@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN content_requested_at TIMESTAMP;
+ALTER TABLE posts ADD COLUMN content_lease_expires_at TIMESTAMP;
+ALTER TABLE posts ADD COLUMN content_attempts INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX posts_content_requested_at_idx ON posts (content_requested_at)
+WHERE content_requested_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX posts_content_requested_at_idx;
+ALTER TABLE posts DROP COLUMN content_attempts;
+ALTER TABLE posts DROP COLUMN content_lease_expires_at;
+ALTER TABLE posts DROP COLUMN content_requested_at;
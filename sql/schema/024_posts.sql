@@ -0,0 +1,10 @@
+-- +goose Up
+-- the posts stored before their text was normalized are normalized and rehashed by the aggregator,
+-- since SQL can't decode their entities as normalizeText does, the new posts being normalized already
+ALTER TABLE posts ADD COLUMN text_normalized BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE posts ALTER COLUMN text_normalized SET DEFAULT true;
+CREATE INDEX posts_text_not_normalized_idx ON posts (id) WHERE NOT text_normalized;
+
+-- +goose Down
+DROP INDEX posts_text_not_normalized_idx;
+ALTER TABLE posts DROP COLUMN text_normalized;